@@ -2,12 +2,13 @@ package main
 
 import (
 	"context"
+	"flag"
 	"log"
-	"os"
-	"os/signal"
-	"syscall"
 
+	"github.com/charlieegan3/toolbelt/pkg/config"
 	"github.com/charlieegan3/toolbelt/pkg/example"
+	"github.com/charlieegan3/toolbelt/pkg/runners/container"
+	"github.com/charlieegan3/toolbelt/pkg/runners/local"
 	"github.com/charlieegan3/toolbelt/pkg/tool"
 )
 
@@ -15,45 +16,64 @@ import (
 func main() {
 	tb := tool.NewBelt()
 
-	// this might be loaded from disk in some real example
-	tb.SetConfig(map[string]any{
-		"config-tool": map[string]any{
-			"exampleValue": "example config value",
-		},
-	})
+	configPath := flag.String("config", "", "path to a YAML, TOML or JSON config file")
+	flag.Parse()
 
-	err := tb.AddTool(context.Background(), &example.HelloWorld{})
-	if err != nil {
-		log.Fatalf("failed to add tool: %v", err)
-	}
+	if *configPath != "" {
+		cfg, err := config.Load(*configPath)
+		if err != nil {
+			log.Fatalf("failed to load config: %v", err)
+		}
 
-	err = tb.AddTool(context.Background(), &example.ConfigTool{})
-	if err != nil {
-		log.Fatalf("failed to add tool: %v", err)
+		tb.SetConfig(cfg)
+	} else {
+		tb.SetConfig(map[string]any{
+			"config-tool": map[string]any{
+				"exampleValue": "example config value",
+			},
+			"typed-config-tool": map[string]any{
+				"greeting": "hello ",
+				"repeat":   3,
+			},
+		})
 	}
 
+	// external jobs selecting the local runner are run as processes on this host
+	tb.AddExternalJobRunner(local.NewRunner())
+	// external jobs selecting the container runner are run with the docker CLI
+	tb.AddExternalJobRunner(container.NewRunner())
+
 	count := 0
-	err = tb.AddTool(context.Background(), &example.JobsTool{Count: &count})
-	if err != nil {
-		log.Fatalf("failed to add tool: %v", err)
-	}
 
-	err = tb.AddTool(context.Background(), &example.HostHTTPTool{})
+	// the config for all tools is validated before any are added
+	err := tb.AddTools(
+		context.Background(),
+		&example.HelloWorld{},
+		&example.ConfigTool{},
+		&example.TypedConfigTool{},
+		&example.JobsTool{Count: &count},
+		&example.HostHTTPTool{},
+		tool.NewAdminTool(tb, "admin"),
+	)
 	if err != nil {
-		log.Fatalf("failed to add tool: %v", err)
+		log.Fatalf("failed to add tools: %v", err)
 	}
 
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt, syscall.SIGINT, syscall.SIGTERM)
-	go func() {
-		<-c
-		cancel()
-	}()
-
-	go tb.RunJobs(ctx)
+	if *configPath != "" {
+		go func() {
+			err := tb.WatchConfigFile(ctx, *configPath)
+			if err != nil {
+				log.Printf("failed to watch config file: %v", err)
+			}
+		}()
+	}
 
-	tb.RunServer(ctx, "0.0.0.0", "3000")
+	// the belt runs until it receives SIGINT or SIGTERM
+	err = tb.Run(ctx)
+	if err != nil {
+		log.Fatalf("belt failed: %v", err)
+	}
 }
@@ -0,0 +1,46 @@
+package apis
+
+import (
+	"context"
+	"time"
+)
+
+// Event is a named event published to the belt's event bus, jobs subscribed to the event are run when it's published
+type Event struct {
+	// Name is used by jobs to subscribe to the event
+	Name string
+	// Source is the name of the tool which published the event
+	Source string
+	// Payload is passed to subscribed jobs as is, it should not be modified by jobs as it's shared between them
+	Payload any
+	// PublishedAt is the time the event was published
+	PublishedAt time.Time
+}
+
+type EventsTool interface {
+	// EventsFuncSet sets the function that the tool can use to publish events
+	EventsFuncSet(func(name string, payload any) error)
+}
+
+// EventJob is an optional interface for jobs which are run when events are published. EventJobs can return a blank
+// Schedule to only run on events.
+type EventJob interface {
+	Job
+
+	// Events returns the names of the events which trigger the job
+	Events() []string
+}
+
+type eventKey struct{}
+
+// ContextWithEvent returns a copy of ctx carrying the event which triggered a job
+func ContextWithEvent(ctx context.Context, event Event) context.Context {
+	return context.WithValue(ctx, eventKey{}, event)
+}
+
+// EventFromContext returns the event which triggered the job run using ctx, ok is false for runs not triggered by an
+// event
+func EventFromContext(ctx context.Context) (event Event, ok bool) {
+	event, ok = ctx.Value(eventKey{}).(Event)
+	return event, ok
+}
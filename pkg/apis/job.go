@@ -16,7 +16,9 @@ type Job interface {
 	// Timeout returns the time after which the job should be preemptively killed
 	Timeout() time.Duration
 
-	// Schedule returns the crontab schedule for the job
+	// Schedule returns the crontab schedule for the job. This is six fields starting with seconds, or a descriptor such
+	// as @daily or @every 15m, and can start with CRON_TZ=<timezone> to run the job in a timezone other than the
+	// belt's, e.g. CRON_TZ=Europe/London 0 0 9 * * *
 	Schedule() string
 }
 
@@ -43,3 +45,119 @@ type ExternalJob interface {
 	// Config returns the configuration for the job to be handed to the external job runner
 	Config() map[string]any
 }
+
+// ExternalJobStatus is the state of an external job started with an AsyncExternalJobRunner
+type ExternalJobStatus string
+
+const (
+	ExternalJobPending   ExternalJobStatus = "pending"
+	ExternalJobRunning   ExternalJobStatus = "running"
+	ExternalJobSucceeded ExternalJobStatus = "succeeded"
+	ExternalJobFailed    ExternalJobStatus = "failed"
+)
+
+// Finished returns true if the job will not change status again
+func (s ExternalJobStatus) Finished() bool {
+	return s == ExternalJobSucceeded || s == ExternalJobFailed
+}
+
+// ExternalJobHandle refers to an external job which has been started
+type ExternalJobHandle interface {
+	// ID returns the runner's identifier for the job
+	ID() string
+	// Status returns the current status of the job
+	Status(ctx context.Context) (ExternalJobStatus, error)
+	// Logs returns the output of the job so far
+	Logs(ctx context.Context) (string, error)
+	// Cancel stops the job, the job's status is failed once it has stopped
+	Cancel(ctx context.Context) error
+}
+
+// AsyncExternalJobRunner is an optional interface for runners which can start jobs without waiting for them to finish
+type AsyncExternalJobRunner interface {
+	ExternalJobRunner
+
+	// StartJob starts the job and returns a handle to follow its progress
+	StartJob(ctx context.Context, job ExternalJob) (ExternalJobHandle, error)
+}
+
+// BackoffStrategy selects how the delay between attempts of a job grows
+type BackoffStrategy string
+
+const (
+	// BackoffFixed waits the same interval between each attempt
+	BackoffFixed BackoffStrategy = "fixed"
+	// BackoffExponential doubles the interval after each attempt
+	BackoffExponential BackoffStrategy = "exponential"
+)
+
+// RetryPolicy describes how a failed job should be retried
+type RetryPolicy struct {
+	// MaxAttempts is the maximum number of times the job is run, including the first attempt
+	MaxAttempts int
+
+	// Backoff selects how the delay between attempts grows, fixed is used when unset
+	Backoff BackoffStrategy
+	// InitialInterval is the delay before the first retry
+	InitialInterval time.Duration
+	// MaxInterval, if set, caps the delay between attempts
+	MaxInterval time.Duration
+	// Jitter is the fraction of each delay, between 0 and 1, by which the delay is randomly varied
+	Jitter float64
+
+	// Budget, if set, is the total time allowed for all attempts and each attempt is limited by the job's Timeout.
+	// When unset, all attempts must complete within the job's Timeout.
+	Budget time.Duration
+
+	// Retryable returns true if the attempt which returned err should be retried, all errors are retried when unset.
+	// Panics are never retried.
+	Retryable func(err error) bool
+}
+
+// RetryableJob is an optional interface for jobs which should be retried when they fail
+type RetryableJob interface {
+	Job
+
+	// RetryPolicy returns the policy used to retry the job
+	RetryPolicy() RetryPolicy
+}
+
+// ConcurrencyPolicy describes what happens when a job's schedule fires while a previous run of the job is still going,
+// the policies match those of Kubernetes CronJobs
+type ConcurrencyPolicy string
+
+const (
+	// ConcurrencyAllow starts a new run alongside the running one
+	ConcurrencyAllow ConcurrencyPolicy = "Allow"
+	// ConcurrencyForbid skips the new run
+	ConcurrencyForbid ConcurrencyPolicy = "Forbid"
+	// ConcurrencyReplace cancels the running one and then starts the new run
+	ConcurrencyReplace ConcurrencyPolicy = "Replace"
+)
+
+// ConcurrencyPolicyJob is an optional interface for jobs which should not run alongside previous runs of themselves.
+// Jobs which do not implement this interface use ConcurrencyAllow.
+type ConcurrencyPolicyJob interface {
+	Job
+
+	// ConcurrencyPolicy returns the policy used when the job's schedule fires during a previous run
+	ConcurrencyPolicy() ConcurrencyPolicy
+}
+
+// CatchUpPolicy configures how the belt runs occurrences of a job which were missed while the belt was not running
+type CatchUpPolicy struct {
+	// MaxRuns is the number of missed occurrences which are run when the belt starts. When set to 1, the job is run
+	// once however many occurrences were missed, otherwise the most recent MaxRuns occurrences are each run in turn.
+	MaxRuns int
+	// MaxAge, if set, is how far back missed occurrences are looked for
+	MaxAge time.Duration
+}
+
+// CatchUpJob is an optional interface for jobs which should be run when occurrences of the job were missed. Catching
+// up requires a database, the time of the job's last successful run is taken from the job run history.
+type CatchUpJob interface {
+	Job
+
+	// CatchUpPolicy returns the policy used to run missed occurrences of the job
+	CatchUpPolicy() CatchUpPolicy
+}
@@ -0,0 +1,47 @@
+package apis
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+// Task is a unit of background work in the belt's task queue
+type Task struct {
+	ID       int64
+	ToolName string
+	// Kind selects the handler which processes the task
+	Kind string
+	// Payload is the JSON encoded payload the task was enqueued with
+	Payload json.RawMessage
+	// Attempt is the number of this attempt to process the task, starting at 1
+	Attempt     int
+	MaxAttempts int
+	CreatedAt   time.Time
+}
+
+// EnqueueOptions are the optional settings for an enqueued task
+type EnqueueOptions struct {
+	// Delay is how long to wait before the task can be processed
+	Delay time.Duration
+	// DedupeKey, if set, prevents the task being enqueued when a task of the same kind and key is already waiting to
+	// be processed
+	DedupeKey string
+	// MaxAttempts is the number of times the task is attempted before it's dead-lettered, the belt's default is used
+	// when this is zero
+	MaxAttempts int
+}
+
+// TaskHandler processes a task, tasks are retried with a backoff when an error is returned. The context is cancelled
+// when the queue's visibility timeout is reached, after which the task may be processed again.
+type TaskHandler func(ctx context.Context, task Task) error
+
+// EnqueueFunc adds a task of the given kind to the task queue, the payload is encoded as JSON
+type EnqueueFunc func(ctx context.Context, kind string, payload any, opts EnqueueOptions) error
+
+type QueueTool interface {
+	// QueueHandlers returns the handler for each kind of task the tool processes
+	QueueHandlers() map[string]TaskHandler
+	// QueueFuncSet sets the function that the tool can use to enqueue tasks
+	QueueFuncSet(enqueue EnqueueFunc)
+}
@@ -0,0 +1,13 @@
+package apis
+
+import "context"
+
+// SecretProvider is an interface which defines a source of secrets for tool config. Config values of the form
+// secret://<provider name>/<path> are resolved by the named provider before the config is passed to the tool.
+type SecretProvider interface {
+	// Name returns the name of the provider, used to select the provider in secret references
+	Name() string
+
+	// Resolve returns the value of the secret at path
+	Resolve(ctx context.Context, path string) (string, error)
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"embed"
+	"errors"
 
 	"github.com/gorilla/mux"
 )
@@ -12,26 +13,39 @@ import (
 // the tool belt.
 type FeatureSet struct {
 	// Config, if true, indicates that the tool expects some config values to be passed at initialization time
-	Config bool
+	Config bool `json:"config"`
 
 	// Database, if true, indicates that the tool expects to connect to the tool belt database, owns a schema and will
 	// have migrations that must be run.
-	Database bool
+	Database bool `json:"database"`
 
 	// HTTP, if true, indicates that the tool needs to mount a subrouter on the tool belt webserver
-	HTTP bool
+	HTTP bool `json:"http"`
 
 	// HTTPHost, if true, indicates that the tool needs a subrouter with a host matcher
-	HTTPHost bool
+	HTTPHost bool `json:"http_host"`
 
 	// TCP, if true, indicates that the tool needs to listen on a TCP port
-	TCP bool
+	TCP bool `json:"tcp"`
 
 	// Jobs, if true, indicates that the tool has jobs which the belt must run
-	Jobs bool
+	Jobs bool `json:"jobs"`
 
 	// ExternalJobs, if true, indicates that the tool needs a function by which to start external jobs
-	ExternalJobs bool
+	ExternalJobs bool `json:"external_jobs"`
+
+	// Lifecycle, if true, indicates that the tool needs to be started and stopped along with the belt
+	Lifecycle bool `json:"lifecycle"`
+
+	// Health, if true, indicates that the tool has a health check to include in the belt's health endpoints
+	Health bool `json:"health"`
+
+	// Events, if true, indicates that the tool needs a function by which to publish events to the belt's event bus
+	Events bool `json:"events"`
+
+	// Queue, if true, indicates that the tool enqueues tasks to the belt's task queue and has handlers to process them.
+	// The queue requires a database.
+	Queue bool `json:"queue"`
 }
 
 type Tool interface {
@@ -46,6 +60,19 @@ type Tool interface {
 	SetConfig(config map[string]interface{}) error
 }
 
+type TypedConfigTool interface {
+	// ConfigStruct returns a pointer to a struct which the belt decodes and validates the tool's config into before
+	// calling SetConfig, see config.Decode for the supported struct tags
+	ConfigStruct() any
+}
+
+type ReloadableTool interface {
+	// ConfigReload is called with the tool's new config when its section of the belt config changes while the belt
+	// is running. The belt validates typed config before calling ConfigReload but does not update the config struct,
+	// tools can decode the new config with config.Decode and swap it in safely.
+	ConfigReload(config map[string]any) error
+}
+
 type HTTPTool interface {
 	// HTTPPath returns the base path to use for the subrouter
 	HTTPPath() string
@@ -56,7 +83,8 @@ type HTTPTool interface {
 }
 
 type TCPTool interface {
-	// TCPStart initializes one or more TCP listeners for the tool
+	// TCPStart initializes one or more TCP listeners for the tool. It is called by the belt's StartTools and Run
+	// rather than when the tool is added, the listeners should be closed when ctx is cancelled.
 	TCPStart(ctx context.Context) error
 }
 
@@ -77,3 +105,26 @@ type ExternalJobsTool interface {
 	// use to start external jobs
 	ExternalJobsFuncSet(func(job ExternalJob) error)
 }
+
+type AsyncExternalJobsTool interface {
+	// ExternalJobsStartFuncSet sets the function that the tool can use to start external jobs without waiting for
+	// them to finish
+	ExternalJobsStartFuncSet(func(ctx context.Context, job ExternalJob) (ExternalJobHandle, error))
+}
+
+type LifecycleTool interface {
+	// LifecycleStart is called when the belt starts, tools are started in the order they were added to the belt
+	LifecycleStart(ctx context.Context) error
+	// LifecycleStop is called when the belt shuts down, tools are stopped in the reverse order to which they were
+	// started. The context is cancelled when the belt's shutdown deadline is reached.
+	LifecycleStop(ctx context.Context) error
+}
+
+// ErrHealthNonCritical can be wrapped by errors returned from HealthCheck to report a problem which should not fail
+// the belt's health endpoints
+var ErrHealthNonCritical = errors.New("non-critical")
+
+type HealthTool interface {
+	// HealthCheck returns an error if the tool is unhealthy
+	HealthCheck(ctx context.Context) error
+}
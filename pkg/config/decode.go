@@ -0,0 +1,380 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// Problem is a single missing or invalid config value
+type Problem struct {
+	// Key is the dotted path to the value in the config
+	Key     string
+	Message string
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s", p.Key, p.Message)
+}
+
+// ValidationError lists every problem found when decoding config
+type ValidationError struct {
+	Problems []Problem
+}
+
+func (e *ValidationError) Error() string {
+	var problems []string
+	for _, p := range e.Problems {
+		problems = append(problems, p.String())
+	}
+
+	return fmt.Sprintf("invalid config: %s", strings.Join(problems, "; "))
+}
+
+// Add records a problem with the value at key
+func (e *ValidationError) Add(key, format string, args ...any) {
+	e.Problems = append(e.Problems, Problem{Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+// Merge adds the problems from other with their keys prefixed, this is used to combine the errors for many tools
+func (e *ValidationError) Merge(prefix string, other *ValidationError) {
+	for _, p := range other.Problems {
+		e.Problems = append(e.Problems, Problem{Key: joinKey(prefix, p.Key), Message: p.Message})
+	}
+}
+
+// ErrorOrNil returns the error if any problems were recorded, and nil otherwise
+func (e *ValidationError) ErrorOrNil() error {
+	if len(e.Problems) == 0 {
+		return nil
+	}
+
+	return e
+}
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decode sets the fields of the struct pointed to by target from raw. Fields are configured with struct tags:
+//
+//	Port int `config:"port,required" default:"8080" validate:"min=1,max=65535"`
+//
+// The config tag sets the key to read, which defaults to the field name with a lowercase first letter, and may mark
+// the key as required. The default tag is used when the key is missing. The validate tag is a comma separated list
+// of rules: min=n and max=n check numbers, or the length of strings, slices and maps, oneof=a b c checks the value is
+// one of a space separated list and nonempty checks that the value is not the zero value.
+//
+// All problems are returned together in a *ValidationError.
+func Decode(raw map[string]any, target any) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config target must be a pointer to a struct, got %T", target)
+	}
+
+	errs := &ValidationError{}
+	decodeStruct("", raw, v.Elem(), errs)
+
+	return errs.ErrorOrNil()
+}
+
+func decodeStruct(prefix string, raw map[string]any, v reflect.Value, errs *ValidationError) {
+	t := v.Type()
+
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, required := parseConfigTag(field)
+		if name == "-" {
+			continue
+		}
+		key := joinKey(prefix, name)
+
+		rawValue, ok := raw[name]
+		switch {
+		case ok:
+			err := assign(key, rawValue, v.Field(i), errs)
+			if err != nil {
+				errs.Add(key, "%s", err)
+				continue
+			}
+		case field.Tag.Get("default") != "":
+			err := assign(key, field.Tag.Get("default"), v.Field(i), errs)
+			if err != nil {
+				errs.Add(key, "invalid default: %s", err)
+				continue
+			}
+		case field.Type.Kind() == reflect.Struct && field.Type != durationType:
+			// nested structs are decoded even when missing so that their own defaults and required keys apply
+			decodeStruct(key, map[string]any{}, v.Field(i), errs)
+			continue
+		case required:
+			errs.Add(key, "is required")
+			continue
+		default:
+			continue
+		}
+
+		validate(key, field.Tag.Get("validate"), v.Field(i), errs)
+	}
+}
+
+func parseConfigTag(field reflect.StructField) (string, bool) {
+	parts := strings.Split(field.Tag.Get("config"), ",")
+
+	name := parts[0]
+	if name == "" {
+		runes := []rune(field.Name)
+		runes[0] = unicode.ToLower(runes[0])
+		name = string(runes)
+	}
+
+	required := false
+	for _, option := range parts[1:] {
+		if option == "required" {
+			required = true
+		}
+	}
+
+	return name, required
+}
+
+// assign sets v from a raw config value, strings are parsed for non-string fields so that defaults and values from
+// environment variables can be used
+func assign(key string, raw any, v reflect.Value, errs *ValidationError) error {
+	if v.Type() == durationType {
+		switch r := raw.(type) {
+		case string:
+			d, err := time.ParseDuration(r)
+			if err != nil {
+				return fmt.Errorf("must be a duration: %s", err)
+			}
+			v.SetInt(int64(d))
+			return nil
+		default:
+			return fmt.Errorf("must be a duration string, got %T", raw)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		s, ok := raw.(string)
+		if !ok {
+			return fmt.Errorf("must be a string, got %T", raw)
+		}
+		v.SetString(s)
+
+	case reflect.Bool:
+		switch r := raw.(type) {
+		case bool:
+			v.SetBool(r)
+		case string:
+			b, err := strconv.ParseBool(r)
+			if err != nil {
+				return fmt.Errorf("must be a bool: %s", err)
+			}
+			v.SetBool(b)
+		default:
+			return fmt.Errorf("must be a bool, got %T", raw)
+		}
+
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		f, err := toFloat(raw)
+		if err != nil || f != float64(int64(f)) {
+			return fmt.Errorf("must be an integer, got %v", raw)
+		}
+		if v.OverflowInt(int64(f)) {
+			return fmt.Errorf("%v is out of range", raw)
+		}
+		v.SetInt(int64(f))
+
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		f, err := toFloat(raw)
+		if err != nil || f < 0 || f != float64(uint64(f)) {
+			return fmt.Errorf("must be a positive integer, got %v", raw)
+		}
+		if v.OverflowUint(uint64(f)) {
+			return fmt.Errorf("%v is out of range", raw)
+		}
+		v.SetUint(uint64(f))
+
+	case reflect.Float32, reflect.Float64:
+		f, err := toFloat(raw)
+		if err != nil {
+			return fmt.Errorf("must be a number, got %v", raw)
+		}
+		v.SetFloat(f)
+
+	case reflect.Slice:
+		var items []any
+		switch r := raw.(type) {
+		case []any:
+			items = r
+		case []string:
+			for _, item := range r {
+				items = append(items, item)
+			}
+		case string:
+			for _, item := range strings.Split(r, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		default:
+			return fmt.Errorf("must be a list, got %T", raw)
+		}
+
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			err := assign(fmt.Sprintf("%s[%d]", key, i), item, slice.Index(i), errs)
+			if err != nil {
+				return fmt.Errorf("item %d %s", i, err)
+			}
+		}
+		v.Set(slice)
+
+	case reflect.Map:
+		r, ok := raw.(map[string]any)
+		if !ok || v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("must be a map, got %T", raw)
+		}
+
+		m := reflect.MakeMapWithSize(v.Type(), len(r))
+		for _, k := range sortedKeys(r) {
+			elem := reflect.New(v.Type().Elem()).Elem()
+			err := assign(joinKey(key, k), r[k], elem, errs)
+			if err != nil {
+				return fmt.Errorf("key %s %s", k, err)
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(v.Type().Key()), elem)
+		}
+		v.Set(m)
+
+	case reflect.Struct:
+		r, ok := raw.(map[string]any)
+		if !ok {
+			return fmt.Errorf("must be a map, got %T", raw)
+		}
+		decodeStruct(key, r, v, errs)
+
+	case reflect.Interface:
+		if raw == nil {
+			return nil
+		}
+		if !reflect.TypeOf(raw).AssignableTo(v.Type()) {
+			return fmt.Errorf("must be a %s, got %T", v.Type(), raw)
+		}
+		v.Set(reflect.ValueOf(raw))
+
+	default:
+		return fmt.Errorf("unsupported config field type %s", v.Type())
+	}
+
+	return nil
+}
+
+func toFloat(raw any) (float64, error) {
+	switch r := raw.(type) {
+	case int:
+		return float64(r), nil
+	case int64:
+		return float64(r), nil
+	case uint64:
+		return float64(r), nil
+	case float64:
+		return r, nil
+	case string:
+		return strconv.ParseFloat(r, 64)
+	default:
+		return 0, fmt.Errorf("unexpected type %T", raw)
+	}
+}
+
+func validate(key, rules string, v reflect.Value, errs *ValidationError) {
+	if rules == "" {
+		return
+	}
+
+	for _, rule := range strings.Split(rules, ",") {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		switch name {
+		case "nonempty":
+			if v.IsZero() {
+				errs.Add(key, "must not be empty")
+			}
+		case "min", "max":
+			limit, err := strconv.ParseFloat(arg, 64)
+			if v.Type() == durationType {
+				var d time.Duration
+				d, err = time.ParseDuration(arg)
+				limit = float64(d)
+			}
+			if err != nil {
+				errs.Add(key, "invalid %s rule %q", name, arg)
+				continue
+			}
+
+			value, unit := measure(v)
+			if name == "min" && value < limit {
+				errs.Add(key, "must be at least %s%s", arg, unit)
+			}
+			if name == "max" && value > limit {
+				errs.Add(key, "must be at most %s%s", arg, unit)
+			}
+		case "oneof":
+			options := strings.Fields(arg)
+			value := fmt.Sprint(v.Interface())
+
+			found := false
+			for _, option := range options {
+				if option == value {
+					found = true
+					break
+				}
+			}
+			if !found {
+				errs.Add(key, "must be one of %s, got %q", strings.Join(options, ", "), value)
+			}
+		default:
+			errs.Add(key, "unknown validation rule %q", name)
+		}
+	}
+}
+
+// measure returns the value compared against min and max rules, the length is used for strings, slices and maps
+func measure(v reflect.Value) (float64, string) {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map:
+		return float64(v.Len()), " in length"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), ""
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), ""
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), ""
+	default:
+		return 0, ""
+	}
+}
+
+func joinKey(prefix, key string) string {
+	if prefix == "" {
+		return key
+	}
+
+	return prefix + "." + key
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	return keys
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testConfig struct {
+	Name    string        `config:"name,required" validate:"nonempty"`
+	Port    int           `config:"port" default:"8080" validate:"min=1,max=65535"`
+	Mode    string        `config:"mode" default:"fast" validate:"oneof=fast slow"`
+	Timeout time.Duration `config:"timeout" default:"5s"`
+	Tags    []string      `config:"tags"`
+	Nested  struct {
+		Token string `config:"token,required"`
+	} `config:"nested"`
+}
+
+func TestDecode(t *testing.T) {
+	var c testConfig
+	err := Decode(map[string]any{
+		"name": "example",
+		"port": 3000,
+		"tags": []any{"a", "b"},
+		"nested": map[string]any{
+			"token": "secret",
+		},
+	}, &c)
+	require.NoError(t, err)
+
+	require.Equal(t, "example", c.Name)
+	require.Equal(t, 3000, c.Port)
+	require.Equal(t, "fast", c.Mode)
+	require.Equal(t, 5*time.Second, c.Timeout)
+	require.Equal(t, []string{"a", "b"}, c.Tags)
+	require.Equal(t, "secret", c.Nested.Token)
+}
+
+func TestDecodeProblems(t *testing.T) {
+	var c testConfig
+	err := Decode(map[string]any{
+		"port":    70000,
+		"mode":    "medium",
+		"timeout": "soon",
+	}, &c)
+
+	var validationErr *ValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	require.Equal(t, []Problem{
+		{Key: "name", Message: "is required"},
+		{Key: "port", Message: "must be at most 65535"},
+		{Key: "mode", Message: `must be one of fast, slow, got "medium"`},
+		{Key: "timeout", Message: `must be a duration: time: invalid duration "soon"`},
+		{Key: "nested.token", Message: "is required"},
+	}, validationErr.Problems)
+}
@@ -0,0 +1,199 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// EnvPrefix is the prefix of environment variables which override values in loaded config
+const EnvPrefix = "TOOLBELT_"
+
+// Load reads belt config from a YAML, TOML or JSON file, the format is selected by the file extension. Values are
+// then overridden from the environment and ${VAR} references in string values are expanded.
+//
+// Environment variables are matched to existing keys in the config. The name after EnvPrefix is the path to the key,
+// with each key uppercased, other characters replaced with underscores and the keys joined with underscores. For
+// example, TOOLBELT_CONFIG_TOOL_EXAMPLEVALUE overrides exampleValue in the config-tool section. Overridden values
+// are converted to the type of the value they replace. Variables naming a key missing from an existing section create
+// the key, lowercased, with a string value, e.g. TOOLBELT_CONFIG_TOOL_TOKEN sets token in the config-tool section.
+// Variables which don't match a section are logged and ignored.
+func Load(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config := map[string]any{}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &config)
+	case ".toml":
+		err = toml.Unmarshal(data, &config)
+	case ".json":
+		err = json.Unmarshal(data, &config)
+	default:
+		return nil, fmt.Errorf("unsupported config file extension %q", ext)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	err = applyEnvOverrides(config, os.Environ())
+	if err != nil {
+		return nil, fmt.Errorf("failed to apply environment overrides: %w", err)
+	}
+
+	err = expandEnv(config, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to expand environment references: %w", err)
+	}
+
+	return config, nil
+}
+
+// applyEnvOverrides sets values in config from environment variables starting with EnvPrefix, variables which do not
+// match an existing section are logged and ignored
+func applyEnvOverrides(config map[string]any, environ []string) error {
+	// sort the variables so that overrides are applied in a predictable order
+	sort.Strings(environ)
+
+	for _, env := range environ {
+		name, value, ok := strings.Cut(env, "=")
+		if !ok || !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+
+		matched, err := override(config, strings.TrimPrefix(name, EnvPrefix), value, false)
+		if err != nil {
+			return fmt.Errorf("failed to override from %s: %w", name, err)
+		}
+		if !matched {
+			log.Printf("ignoring environment variable %s, it does not match a section of the config", name)
+		}
+	}
+
+	return nil
+}
+
+// override sets the value at the key path matching name. If m is a section of the config and no key matches, the key is
+// created with the string value. It returns false if name matches no key or section.
+func override(m map[string]any, name, value string, section bool) (bool, error) {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	// prefer longer keys so that a key containing an underscore is matched before a shorter key sharing a prefix
+	sort.Slice(keys, func(i, j int) bool {
+		if len(keys[i]) != len(keys[j]) {
+			return len(keys[i]) > len(keys[j])
+		}
+		return keys[i] < keys[j]
+	})
+
+	for _, k := range keys {
+		normalized := envName(k)
+
+		if name == normalized {
+			converted, err := convert(m[k], value)
+			if err != nil {
+				return false, fmt.Errorf("invalid value for %s: %w", k, err)
+			}
+			m[k] = converted
+			return true, nil
+		}
+
+		nested, ok := m[k].(map[string]any)
+		if ok && strings.HasPrefix(name, normalized+"_") {
+			return override(nested, strings.TrimPrefix(name, normalized+"_"), value, true)
+		}
+	}
+
+	if section {
+		m[strings.ToLower(name)] = value
+		return true, nil
+	}
+
+	return false, nil
+}
+
+var envNameReplacer = regexp.MustCompile(`[^A-Z0-9]`)
+
+// envName returns the form of a config key used in environment variable names
+func envName(key string) string {
+	return envNameReplacer.ReplaceAllString(strings.ToUpper(key), "_")
+}
+
+// convert parses value into the type of existing, maps and lists can't be overridden
+func convert(existing any, value string) (any, error) {
+	switch existing.(type) {
+	case string, nil:
+		return value, nil
+	case bool:
+		return strconv.ParseBool(value)
+	case int:
+		return strconv.Atoi(value)
+	case int64:
+		return strconv.ParseInt(value, 10, 64)
+	case uint64:
+		return strconv.ParseUint(value, 10, 64)
+	case float64:
+		return strconv.ParseFloat(value, 64)
+	default:
+		return nil, fmt.Errorf("values of type %T can't be set from the environment", existing)
+	}
+}
+
+var envReference = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// expandEnv replaces ${VAR} references in all string values in config, referencing an unset variable is an error
+func expandEnv(config any, lookup func(string) (string, bool)) error {
+	var missing []string
+
+	var expand func(v any) any
+	expand = func(v any) any {
+		switch value := v.(type) {
+		case string:
+			return envReference.ReplaceAllStringFunc(value, func(ref string) string {
+				name := envReference.FindStringSubmatch(ref)[1]
+				envValue, ok := lookup(name)
+				if !ok {
+					missing = append(missing, name)
+				}
+				return envValue
+			})
+		case map[string]any:
+			for k, item := range value {
+				value[k] = expand(item)
+			}
+			return value
+		case []any:
+			for i, item := range value {
+				value[i] = expand(item)
+			}
+			return value
+		default:
+			return v
+		}
+	}
+
+	expand(config)
+
+	if len(missing) > 0 {
+		sort.Strings(missing)
+		return fmt.Errorf("environment variables are not set: %s", strings.Join(missing, ", "))
+	}
+
+	return nil
+}
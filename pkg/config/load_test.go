@@ -0,0 +1,73 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	files := map[string]string{
+		"config.yaml": `
+config-tool:
+  exampleValue: ${EXAMPLE_VALUE}
+  enabled: false
+server:
+  timeout:
+    read: 10s
+`,
+		"config.toml": `
+[config-tool]
+exampleValue = "${EXAMPLE_VALUE}"
+enabled = false
+
+[server.timeout]
+read = "10s"
+`,
+		"config.json": `{
+  "config-tool": {"exampleValue": "${EXAMPLE_VALUE}", "enabled": false},
+  "server": {"timeout": {"read": "10s"}}
+}`,
+	}
+
+	t.Setenv("EXAMPLE_VALUE", "from env reference")
+	t.Setenv("TOOLBELT_CONFIG_TOOL_ENABLED", "true")
+	t.Setenv("TOOLBELT_SERVER_TIMEOUT_READ", "20s")
+	t.Setenv("TOOLBELT_CONFIG_TOOL_TOKEN", "from env override")
+	t.Setenv("TOOLBELT_UNKNOWN_KEY", "ignored")
+
+	for name, contents := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			err := os.WriteFile(path, []byte(contents), 0o600)
+			require.NoError(t, err)
+
+			config, err := Load(path)
+			require.NoError(t, err)
+
+			require.Equal(t, map[string]any{
+				"config-tool": map[string]any{
+					"exampleValue": "from env reference",
+					"enabled":      true,
+					"token":        "from env override",
+				},
+				"server": map[string]any{
+					"timeout": map[string]any{
+						"read": "20s",
+					},
+				},
+			}, config)
+		})
+	}
+}
+
+func TestLoadMissingReference(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("tool:\n  token: ${TOOLBELT_TEST_UNSET}\n"), 0o600)
+	require.NoError(t, err)
+
+	_, err = Load(path)
+	require.ErrorContains(t, err, "environment variables are not set: TOOLBELT_TEST_UNSET")
+}
@@ -17,6 +17,7 @@ func TestDatabaseSuite(t *testing.T) {
 
 	s.AddDependentSuite(&example.ExampleDatabaseToolSuite{DB: s.DB})
 	s.AddDependentSuite(&example.ExampleJobsToolSuite{DB: s.DB})
+	s.AddDependentSuite(&example.ExampleQueueToolSuite{DB: s.DB})
 
 	s.Run(t)
 }
@@ -35,16 +35,15 @@ func (s *ExampleDatabaseToolSuite) TestDatabaseTool() {
 	err := tb.AddTool(context.Background(), databaseTool)
 	require.NoError(t, err)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	go tb.RunServer(ctx, "0.0.0.0", "9032")
+	server, err := tb.StartServer("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Shutdown(context.Background())
 
 	req := &http.Request{
 		Method: "GET",
 		URL: &url.URL{
 			Scheme: "http",
-			Host:   "localhost:9032",
+			Host:   server.Addr().String(),
 			Path:   "/database/",
 		},
 	}
@@ -3,6 +3,7 @@ package example
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"sync"
 	"testing"
 	"time"
@@ -10,6 +11,7 @@ import (
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 
+	"github.com/charlieegan3/toolbelt/pkg/apis"
 	"github.com/charlieegan3/toolbelt/pkg/tool"
 )
 
@@ -52,4 +54,281 @@ func (s *ExampleJobsToolSuite) TestJobsTool() {
 	wg.Wait()
 
 	require.Greaterf(t, count, 0, "example job should have run at least once")
+
+	runs, err := tb.JobRuns(context.Background(), jobsTool.Name(), "example-job", 10)
+	require.NoError(t, err)
+
+	require.NotEmpty(t, runs, "example job runs should have been recorded")
+	require.Equal(t, tool.JobOutcomeSucceeded, runs[0].Outcome)
+}
+
+func (s *ExampleJobsToolSuite) TestJobsToolLocking() {
+	t := s.T()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// two belts sharing a database act as replicas, each occurrence of the job should run on only one of them
+	var counts [2]int
+	for i := range counts {
+		tb := tool.NewBelt()
+		tb.SetDatabase(s.DB)
+		tb.SetConfig(map[string]any{
+			"jobs": map[string]any{
+				"locking": map[string]any{"enabled": true},
+			},
+		})
+
+		err := tb.AddTool(context.Background(), &JobsTool{Count: &counts[i]})
+		require.NoError(t, err)
+
+		go tb.RunJobs(ctx)
+	}
+
+	time.Sleep(3 * time.Second)
+
+	tb := tool.NewBelt()
+	tb.SetDatabase(s.DB)
+
+	runs, err := tb.JobRuns(context.Background(), "jobs", "example-job", 100)
+	require.NoError(t, err)
+
+	skipped := 0
+	for _, run := range runs {
+		if run.Outcome == tool.JobOutcomeSkipped {
+			skipped++
+		}
+	}
+
+	require.Greaterf(t, skipped, 0, "example job should have been skipped by one of the belts")
+	require.Greaterf(t, counts[0]+counts[1], 0, "example job should have run at least once")
+}
+
+// catchUpJob counts its runs and runs up to three missed occurrences when the belt starts
+type catchUpJob struct {
+	mu    sync.Mutex
+	count int
+}
+
+func (c *catchUpJob) Name() string { return "catch-up-job" }
+
+func (c *catchUpJob) Run(ctx context.Context) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count++
+	return nil
+}
+
+func (c *catchUpJob) Timeout() time.Duration { return time.Second }
+
+func (c *catchUpJob) Schedule() string { return "@every 10m" }
+
+func (c *catchUpJob) CatchUpPolicy() apis.CatchUpPolicy { return apis.CatchUpPolicy{MaxRuns: 3} }
+
+type catchUpTool struct {
+	job *catchUpJob
+}
+
+func (c *catchUpTool) Name() string { return "catch-up-tool" }
+
+func (c *catchUpTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Jobs: true} }
+
+func (c *catchUpTool) SetConfig(config map[string]any) error { return nil }
+
+func (c *catchUpTool) Jobs() ([]apis.Job, error) { return []apis.Job{c.job}, nil }
+
+func (s *ExampleJobsToolSuite) TestJobsToolCatchUp() {
+	t := s.T()
+
+	tb := tool.NewBelt()
+	tb.SetDatabase(s.DB)
+
+	job := &catchUpJob{}
+	err := tb.AddTool(context.Background(), &catchUpTool{job: job})
+	require.NoError(t, err)
+
+	// the job last succeeded an hour ago, so six occurrences were missed since
+	_, err = s.DB.Exec(
+		`INSERT INTO toolbelt.job_runs (tool_name, job_name, started_at, finished_at, outcome, error, panic)
+		VALUES ($1, $2, $3, $3, $4, '', '')`,
+		"catch-up-tool", "catch-up-job", time.Now().Add(-time.Hour), tool.JobOutcomeSucceeded,
+	)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go tb.RunJobs(ctx)
+
+	require.Eventually(t, func() bool {
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		return job.count == 3
+	}, 5*time.Second, 10*time.Millisecond, "the three most recent missed runs should have been caught up")
+
+	// the catch up runs are recorded along with the original run
+	require.Eventually(t, func() bool {
+		runs, err := tb.JobRuns(context.Background(), "catch-up-tool", "catch-up-job", 10)
+		return err == nil && len(runs) == 4
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// forbiddenCatchUpJob blocks until released and is not run alongside previous runs of itself
+type forbiddenCatchUpJob struct {
+	catchUpJob
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *forbiddenCatchUpJob) Name() string { return "forbidden-catch-up-job" }
+
+func (f *forbiddenCatchUpJob) Run(ctx context.Context) error {
+	f.started <- struct{}{}
+	<-f.release
+	return f.catchUpJob.Run(ctx)
+}
+
+func (f *forbiddenCatchUpJob) ConcurrencyPolicy() apis.ConcurrencyPolicy {
+	return apis.ConcurrencyForbid
+}
+
+type forbiddenCatchUpTool struct {
+	job *forbiddenCatchUpJob
+}
+
+func (f *forbiddenCatchUpTool) Name() string { return "forbidden-catch-up-tool" }
+
+func (f *forbiddenCatchUpTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Jobs: true} }
+
+func (f *forbiddenCatchUpTool) SetConfig(config map[string]any) error { return nil }
+
+func (f *forbiddenCatchUpTool) Jobs() ([]apis.Job, error) { return []apis.Job{f.job}, nil }
+
+func (s *ExampleJobsToolSuite) TestJobsToolCatchUpConcurrency() {
+	t := s.T()
+
+	tb := tool.NewBelt()
+	tb.SetDatabase(s.DB)
+
+	job := &forbiddenCatchUpJob{started: make(chan struct{}, 1), release: make(chan struct{})}
+	err := tb.AddTool(context.Background(), &forbiddenCatchUpTool{job: job})
+	require.NoError(t, err)
+
+	_, err = s.DB.Exec(
+		`INSERT INTO toolbelt.job_runs (tool_name, job_name, started_at, finished_at, outcome, error, panic)
+		VALUES ($1, $2, $3, $3, $4, '', '')`,
+		"forbidden-catch-up-tool", "forbidden-catch-up-job", time.Now().Add(-time.Hour), tool.JobOutcomeSucceeded,
+	)
+	require.NoError(t, err)
+
+	// a run started outside the schedule is in progress when the belt catches up
+	err = tb.StartJobNow("forbidden-catch-up-tool", "forbidden-catch-up-job")
+	require.NoError(t, err)
+	<-job.started
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go tb.RunJobs(ctx)
+
+	// the missed runs are skipped under the job's concurrency policy
+	require.Eventually(t, func() bool {
+		runs, err := tb.JobRuns(context.Background(), "forbidden-catch-up-tool", "forbidden-catch-up-job", 10)
+		return err == nil && len(runs) == 4 && runs[0].Outcome == tool.JobOutcomeSkipped
+	}, 5*time.Second, 10*time.Millisecond)
+
+	close(job.release)
+
+	require.Eventually(t, func() bool {
+		job.mu.Lock()
+		defer job.mu.Unlock()
+		return job.count == 1
+	}, 5*time.Second, 10*time.Millisecond, "only the run started outside the schedule should have run")
+}
+
+// historyJob fails when fail is set, so that runs with each outcome can be recorded
+type historyJob struct {
+	fail bool
+}
+
+func (h *historyJob) Name() string { return "history-job" }
+
+func (h *historyJob) Run(ctx context.Context) error {
+	if h.fail {
+		return errors.New("history job failed")
+	}
+	return nil
+}
+
+func (h *historyJob) Timeout() time.Duration { return time.Second }
+
+func (h *historyJob) Schedule() string { return "@every 10m" }
+
+type historyTool struct {
+	job *historyJob
+}
+
+func (h *historyTool) Name() string { return "history-tool" }
+
+func (h *historyTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Jobs: true} }
+
+func (h *historyTool) SetConfig(config map[string]any) error { return nil }
+
+func (h *historyTool) Jobs() ([]apis.Job, error) { return []apis.Job{h.job}, nil }
+
+func (s *ExampleJobsToolSuite) TestJobsToolHistoryRetention() {
+	t := s.T()
+
+	tb := tool.NewBelt()
+	tb.SetDatabase(s.DB)
+	tb.SetConfig(map[string]any{
+		"jobs": map[string]any{
+			"history": map[string]any{"retention": "24h"},
+		},
+	})
+
+	job := &historyJob{fail: true}
+	err := tb.AddTool(context.Background(), &historyTool{job: job})
+	require.NoError(t, err)
+
+	// the belt's tables are created by the first use of the history
+	_, err = tb.JobRuns(context.Background(), "history-tool", "history-job", 1)
+	require.NoError(t, err)
+
+	for _, run := range []struct {
+		age     time.Duration
+		outcome tool.JobOutcome
+	}{
+		{age: 72 * time.Hour, outcome: tool.JobOutcomeSucceeded},
+		{age: 48 * time.Hour, outcome: tool.JobOutcomeFailed},
+		{age: time.Hour, outcome: tool.JobOutcomeFailed},
+	} {
+		_, err = s.DB.Exec(
+			`INSERT INTO toolbelt.job_runs (tool_name, job_name, started_at, finished_at, outcome, error, panic)
+			VALUES ($1, $2, $3, $3, $4, '', '')`,
+			"history-tool", "history-job", time.Now().Add(-run.age), run.outcome,
+		)
+		require.NoError(t, err)
+	}
+
+	// old runs are removed when a run is recorded, except the job's last successful run
+	_, err = tb.RunJobNow(context.Background(), "history-tool", "history-job")
+	require.NoError(t, err)
+
+	runs, err := tb.JobRuns(context.Background(), "history-tool", "history-job", 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	require.Equal(t, tool.JobOutcomeSucceeded, runs[2].Outcome)
+
+	// which is removed once the job succeeds again
+	job.fail = false
+	_, err = tb.RunJobNow(context.Background(), "history-tool", "history-job")
+	require.NoError(t, err)
+
+	runs, err = tb.JobRuns(context.Background(), "history-tool", "history-job", 10)
+	require.NoError(t, err)
+	require.Len(t, runs, 3)
+	for _, run := range runs {
+		require.WithinDuration(t, time.Now(), run.StartedAt, 2*time.Hour)
+	}
 }
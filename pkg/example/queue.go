@@ -0,0 +1,95 @@
+package example
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// QueueTool is an example tool which demonstrates the use of the queue feature. Posting a name to the tool enqueues a
+// greet task, which is processed in the background.
+type QueueTool struct {
+	enqueue apis.EnqueueFunc
+
+	// Greeted, if set, receives the name from each processed greet task
+	Greeted chan string
+}
+
+type greetPayload struct {
+	Name string `json:"name"`
+}
+
+func (q *QueueTool) Name() string {
+	return "queue"
+}
+
+func (q *QueueTool) FeatureSet() apis.FeatureSet {
+	return apis.FeatureSet{
+		HTTP:  true,
+		Queue: true,
+	}
+}
+
+func (q *QueueTool) HTTPPath() string {
+	return "example-queue"
+}
+func (q *QueueTool) HTTPHost() string {
+	return ""
+}
+
+// SetConfig is a no-op for this tool
+func (q *QueueTool) SetConfig(config map[string]any) error {
+	return nil
+}
+
+func (q *QueueTool) QueueFuncSet(enqueue apis.EnqueueFunc) {
+	q.enqueue = enqueue
+}
+
+func (q *QueueTool) QueueHandlers() map[string]apis.TaskHandler {
+	return map[string]apis.TaskHandler{
+		"greet": q.greet,
+	}
+}
+
+func (q *QueueTool) greet(ctx context.Context, task apis.Task) error {
+	var payload greetPayload
+	err := json.Unmarshal(task.Payload, &payload)
+	if err != nil {
+		return fmt.Errorf("failed to decode payload: %w", err)
+	}
+
+	if payload.Name == "" {
+		return fmt.Errorf("name is blank")
+	}
+
+	fmt.Println("hello", payload.Name)
+
+	if q.Greeted != nil {
+		q.Greeted <- payload.Name
+	}
+
+	return nil
+}
+
+func (q *QueueTool) HTTPAttach(router *mux.Router) error {
+	router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		name := request.FormValue("name")
+
+		// repeated requests for the same name are only greeted once
+		err := q.enqueue(request.Context(), "greet", greetPayload{Name: name}, apis.EnqueueOptions{DedupeKey: name})
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+
+		writer.WriteHeader(http.StatusAccepted)
+	}).Methods("POST")
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package example
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+type ExampleQueueToolSuite struct {
+	suite.Suite
+	DB *sql.DB
+}
+
+func (s *ExampleQueueToolSuite) Run(t *testing.T) {
+	suite.Run(t, s)
+}
+
+func (s *ExampleQueueToolSuite) TestQueueTool() {
+	t := s.T()
+
+	tb := tool.NewBelt()
+	tb.SetDatabase(s.DB)
+	tb.SetConfig(map[string]any{
+		"queue": map[string]any{
+			"pollInterval": "50ms",
+		},
+	})
+
+	queueTool := &QueueTool{Greeted: make(chan string, 10)}
+
+	err := tb.AddTool(context.Background(), queueTool)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// the second task is deduplicated while the first is waiting
+	for i := 0; i < 2; i++ {
+		err = queueTool.enqueue(ctx, "greet", greetPayload{Name: "Charlie"}, apis.EnqueueOptions{DedupeKey: "Charlie"})
+		require.NoError(t, err)
+	}
+
+	// a blank name fails and is dead-lettered after its only attempt
+	err = queueTool.enqueue(ctx, "greet", greetPayload{}, apis.EnqueueOptions{MaxAttempts: 1})
+	require.NoError(t, err)
+
+	err = queueTool.enqueue(ctx, "greet", greetPayload{Name: "Later"}, apis.EnqueueOptions{Delay: time.Second})
+	require.NoError(t, err)
+
+	go tb.RunTaskWorkers(ctx)
+
+	var greeted []string
+	for len(greeted) < 2 {
+		select {
+		case name := <-queueTool.Greeted:
+			greeted = append(greeted, name)
+		case <-time.After(5 * time.Second):
+			t.Fatalf("tasks were not processed, greeted %v", greeted)
+		}
+	}
+
+	require.Equal(t, []string{"Charlie", "Later"}, greeted)
+
+	require.Eventually(t, func() bool {
+		dead, err := tb.DeadTasks(context.Background(), queueTool.Name(), 10)
+		return err == nil && len(dead) == 1 && dead[0].Error == "name is blank"
+	}, 5*time.Second, 50*time.Millisecond)
+}
+
+// failingQueueTool has a handler which fails once released, so that tasks can be held running while duplicates are
+// enqueued
+type failingQueueTool struct {
+	enqueue apis.EnqueueFunc
+
+	started chan struct{}
+	release chan struct{}
+}
+
+func (f *failingQueueTool) Name() string { return "failing-queue" }
+
+func (f *failingQueueTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Queue: true} }
+
+func (f *failingQueueTool) SetConfig(config map[string]any) error { return nil }
+
+func (f *failingQueueTool) QueueFuncSet(enqueue apis.EnqueueFunc) { f.enqueue = enqueue }
+
+func (f *failingQueueTool) QueueHandlers() map[string]apis.TaskHandler {
+	return map[string]apis.TaskHandler{
+		"fail": func(ctx context.Context, task apis.Task) error {
+			select {
+			case f.started <- struct{}{}:
+			default:
+			}
+			<-f.release
+			return fmt.Errorf("failed")
+		},
+	}
+}
+
+func (s *ExampleQueueToolSuite) countTasks(dedupeKey string) int {
+	var count int
+	err := s.DB.QueryRow(`SELECT count(*) FROM toolbelt.tasks WHERE dedupe_key = $1`, dedupeKey).Scan(&count)
+	require.NoError(s.T(), err)
+
+	return count
+}
+
+func (s *ExampleQueueToolSuite) TestQueueDedupeConflicts() {
+	t := s.T()
+
+	tb := tool.NewBelt()
+	tb.SetDatabase(s.DB)
+	tb.SetConfig(map[string]any{
+		"queue": map[string]any{
+			"workers":      1,
+			"pollInterval": "50ms",
+		},
+	})
+
+	failingTool := &failingQueueTool{started: make(chan struct{}, 1), release: make(chan struct{})}
+
+	err := tb.AddTool(context.Background(), failingTool)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	err = failingTool.enqueue(ctx, "fail", nil, apis.EnqueueOptions{DedupeKey: "running", MaxAttempts: 3})
+	require.NoError(t, err)
+
+	go tb.RunTaskWorkers(ctx)
+
+	select {
+	case <-failingTool.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("task was not processed")
+	}
+
+	// running tasks don't count towards the dedupe key, the duplicate is delayed so that it stays pending
+	err = failingTool.enqueue(
+		ctx, "fail", nil,
+		apis.EnqueueOptions{DedupeKey: "running", MaxAttempts: 3, Delay: time.Hour},
+	)
+	require.NoError(t, err)
+	require.Equal(t, 2, s.countTasks("running"))
+
+	// the failed task's retry is merged into the pending duplicate rather than failing on the dedupe index
+	close(failingTool.release)
+
+	require.Eventually(t, func() bool {
+		return s.countTasks("running") == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// requeuing a dead task with a pending duplicate leaves just the duplicate
+	err = failingTool.enqueue(ctx, "fail", nil, apis.EnqueueOptions{DedupeKey: "dead", MaxAttempts: 1})
+	require.NoError(t, err)
+
+	var dead []tool.DeadTask
+	require.Eventually(t, func() bool {
+		dead, err = tb.DeadTasks(context.Background(), failingTool.Name(), 10)
+		return err == nil && len(dead) == 1
+	}, 5*time.Second, 50*time.Millisecond)
+
+	err = failingTool.enqueue(ctx, "fail", nil, apis.EnqueueOptions{DedupeKey: "dead", Delay: time.Hour})
+	require.NoError(t, err)
+
+	err = tb.RequeueDeadTask(context.Background(), dead[0].ID)
+	require.NoError(t, err)
+	require.Equal(t, 1, s.countTasks("dead"))
+}
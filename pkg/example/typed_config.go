@@ -0,0 +1,88 @@
+package example
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/config"
+	utilshttp "github.com/charlieegan3/toolbelt/pkg/utils/http"
+)
+
+// TypedConfigTool is an example tool which has its config decoded and validated by the belt, the config can be
+// reloaded while the belt is running
+type TypedConfigTool struct {
+	// mu guards config, which is replaced when the config is reloaded
+	mu     sync.RWMutex
+	config typedConfig
+}
+
+type typedConfig struct {
+	Greeting string `config:"greeting,required" validate:"nonempty"`
+	Repeat   int    `config:"repeat" default:"1" validate:"min=1,max=10"`
+}
+
+func (t *TypedConfigTool) Name() string {
+	return "typed-config-tool"
+}
+
+func (t *TypedConfigTool) FeatureSet() apis.FeatureSet {
+	return apis.FeatureSet{
+		HTTP:   true,
+		Config: true,
+	}
+}
+
+func (t *TypedConfigTool) HTTPPath() string {
+	return "example-typed-config-tool"
+}
+
+func (t *TypedConfigTool) HTTPHost() string {
+	return ""
+}
+
+// ConfigStruct returns the struct which the belt sets from the tool's config
+func (t *TypedConfigTool) ConfigStruct() any {
+	return &t.config
+}
+
+// SetConfig is a no-op for this tool, config is read from the struct set by the belt
+func (t *TypedConfigTool) SetConfig(config map[string]any) error {
+	return nil
+}
+
+// ConfigReload replaces the tool's config, the new config has already been validated by the belt
+func (t *TypedConfigTool) ConfigReload(cfg map[string]any) error {
+	var newConfig typedConfig
+	err := config.Decode(cfg, &newConfig)
+	if err != nil {
+		return err
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.config = newConfig
+
+	return nil
+}
+
+func (t *TypedConfigTool) HTTPAttach(router *mux.Router) error {
+	router.HandleFunc("", utilshttp.BuildRedirectHandler(t.HTTPPath()+"/")).Methods("GET")
+
+	router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		t.mu.RLock()
+		body := strings.Repeat(t.config.Greeting, t.config.Repeat)
+		t.mu.RUnlock()
+
+		_, err := writer.Write([]byte(body))
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+
+	return nil
+}
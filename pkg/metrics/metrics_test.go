@@ -0,0 +1,56 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteText(t *testing.T) {
+	registry := NewRegistry()
+
+	counter := registry.NewCounterVec("requests_total", "Total requests.", "path")
+	counter.Inc("/b")
+	counter.Add(2, "/a")
+	counter.Inc(`/"quoted"`)
+
+	histogram := registry.NewHistogramVec("latency_seconds", "Request latency.", []float64{1, 0.5}, "path")
+	histogram.Observe(0.25, "/a")
+	histogram.Observe(0.75, "/a")
+	histogram.Observe(2, "/a")
+
+	var buf bytes.Buffer
+	err := registry.WriteText(&buf)
+	require.NoError(t, err)
+
+	expected := `# HELP requests_total Total requests.
+# TYPE requests_total counter
+requests_total{path="/\"quoted\""} 1
+requests_total{path="/a"} 2
+requests_total{path="/b"} 1
+# HELP latency_seconds Request latency.
+# TYPE latency_seconds histogram
+latency_seconds_bucket{path="/a",le="0.5"} 1
+latency_seconds_bucket{path="/a",le="1"} 2
+latency_seconds_bucket{path="/a",le="+Inf"} 3
+latency_seconds_sum{path="/a"} 3
+latency_seconds_count{path="/a"} 3
+`
+
+	require.Equal(t, expected, buf.String())
+}
+
+func TestServeHTTP(t *testing.T) {
+	registry := NewRegistry()
+	registry.NewCounterVec("requests_total", "Total requests.", "path").Inc("/a")
+
+	rec := httptest.NewRecorder()
+	registry.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, "text/plain; version=0.0.4; charset=utf-8", rec.Header().Get("Content-Type"))
+	require.Contains(t, rec.Body.String(), `requests_total{path="/a"} 1`)
+}
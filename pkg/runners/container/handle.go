@@ -0,0 +1,65 @@
+package container
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// handleStatusInterval is how often a running container's status is checked while enforcing its timeout
+const handleStatusInterval = time.Second
+
+// handle follows a job started with StartJob
+type handle struct {
+	name      string
+	cliHandle apis.ExternalJobHandle
+	container *runContainer
+
+	cancelOnce sync.Once
+}
+
+func (h *handle) ID() string {
+	return h.name
+}
+
+func (h *handle) Status(ctx context.Context) (apis.ExternalJobStatus, error) {
+	return h.cliHandle.Status(ctx)
+}
+
+// Logs returns the combined stdout and stderr of the container so far
+func (h *handle) Logs(ctx context.Context) (string, error) {
+	return h.cliHandle.Logs(ctx)
+}
+
+// Cancel removes the container and stops the CLI
+func (h *handle) Cancel(ctx context.Context) error {
+	h.cancelOnce.Do(func() {
+		h.container.remove()
+	})
+
+	return h.cliHandle.Cancel(ctx)
+}
+
+// enforceTimeout cancels the job if it's still running when the timeout is reached
+func (h *handle) enforceTimeout(timeout time.Duration) {
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	ticker := time.NewTicker(handleStatusInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-deadline.C:
+			_ = h.Cancel(context.Background())
+			return
+		case <-ticker.C:
+			status, err := h.cliHandle.Status(context.Background())
+			if err == nil && status.Finished() {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,307 @@
+// Package container provides an apis.ExternalJobRunner which runs external jobs in containers using a locally
+// installed container runtime CLI, such as docker or podman.
+package container
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/config"
+	"github.com/charlieegan3/toolbelt/pkg/runners/local"
+)
+
+// RunnerName is the name jobs use to select the container runner
+const RunnerName = "container"
+
+// removeTimeout is the time allowed to remove a container when a job times out or is cancelled
+const removeTimeout = 30 * time.Second
+
+// runnerConfig is the config of the runner, set with Configure
+type runnerConfig struct {
+	// CLI is the container runtime CLI to use, it must accept docker compatible arguments
+	CLI string `config:"cli" default:"docker" validate:"nonempty"`
+	// Timeout is used for jobs which don't set their own timeout
+	Timeout time.Duration `config:"timeout" default:"1h" validate:"min=1s"`
+	// MaxOutputBytes limits how much of each of stdout and stderr is kept
+	MaxOutputBytes int `config:"maxOutputBytes" default:"1048576" validate:"min=1"`
+}
+
+// jobConfig is the config of a job, taken from the job's Config
+type jobConfig struct {
+	Image      string            `config:"image,required" validate:"nonempty"`
+	Entrypoint string            `config:"entrypoint"`
+	Command    []string          `config:"command"`
+	Env        map[string]string `config:"env"`
+	Mounts     []string          `config:"mounts"`
+	Workdir    string            `config:"workdir"`
+	User       string            `config:"user"`
+	Network    string            `config:"network"`
+	CPUs       float64           `config:"cpus" validate:"min=0"`
+	Memory     string            `config:"memory"`
+	Timeout    time.Duration     `config:"timeout"`
+}
+
+// Runner runs external jobs in containers. The job's Config sets the container to run:
+//
+//	image: alpine:3
+//	command: [sh, -c, "du -sh /data"]
+//	env:
+//	  TOKEN: secret
+//	mounts: ["/srv/data:/data:ro"]
+//	workdir: /data
+//	cpus: 0.5
+//	memory: 256m
+//	timeout: 10m
+//
+// Environment variables are passed to the CLI's environment and referenced by name in its arguments, so that their
+// values are not visible in the process list.
+type Runner struct {
+	mu     sync.RWMutex
+	config runnerConfig
+
+	// seq numbers containers so that their names are unique
+	seq atomic.Int64
+}
+
+// NewRunner returns a runner using the default config
+func NewRunner() *Runner {
+	r := &Runner{}
+
+	// the defaults always decode
+	_ = r.Configure(nil)
+
+	return r
+}
+
+func (r *Runner) Name() string {
+	return RunnerName
+}
+
+// Configure sets the runner's config, keys which are not set use their defaults
+func (r *Runner) Configure(cfg map[string]any) error {
+	var c runnerConfig
+	err := config.Decode(cfg, &c)
+	if err != nil {
+		return fmt.Errorf("failed to configure %s runner: %w", RunnerName, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = c
+
+	return nil
+}
+
+// RunJob runs the job's container and waits for it to exit, a *local.ExitError is returned if the job fails
+func (r *Runner) RunJob(job apis.ExternalJob) error {
+	_, err := r.Run(context.Background(), job)
+
+	return err
+}
+
+// Run runs the job's container, waiting for it to exit or for ctx to be cancelled. The container is removed if it
+// does not exit in time, Run returns once it has been removed.
+func (r *Runner) Run(ctx context.Context, job apis.ExternalJob) (local.Result, error) {
+	c, err := r.newContainer(job)
+	if err != nil {
+		return local.Result{}, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+
+	// stopping the CLI does not always stop the container, so it's removed as well
+	done := make(chan struct{})
+	removed := make(chan bool, 1)
+	go func() {
+		select {
+		case <-ctx.Done():
+			c.remove()
+			removed <- true
+		case <-done:
+			removed <- false
+		}
+	}()
+
+	result, err := c.runner.Run(ctx, c.job)
+	close(done)
+
+	// the CLI can return as ctx ends, before the container has been removed
+	if !<-removed && ctx.Err() != nil {
+		c.remove()
+	}
+
+	return result, err
+}
+
+// StartJob starts the job's container and returns without waiting for it to exit. The container is removed if it
+// does not exit before its timeout or if it is cancelled with the handle.
+func (r *Runner) StartJob(ctx context.Context, job apis.ExternalJob) (apis.ExternalJobHandle, error) {
+	c, err := r.newContainer(job)
+	if err != nil {
+		return nil, err
+	}
+
+	cliHandle, err := c.runner.StartJob(ctx, c.job)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &handle{
+		name:      c.name,
+		cliHandle: cliHandle,
+		container: c,
+	}
+
+	go h.enforceTimeout(c.timeout)
+
+	return h, nil
+}
+
+// runContainer is a container for a job which is ready to run with the CLI
+type runContainer struct {
+	name    string
+	cli     string
+	timeout time.Duration
+
+	// runner runs the CLI, job holds the CLI's arguments and environment
+	runner *local.Runner
+	job    *cliJob
+}
+
+// newContainer prepares the CLI invocation for the job
+func (r *Runner) newContainer(job apis.ExternalJob) (*runContainer, error) {
+	r.mu.RLock()
+	runnerCfg := r.config
+	r.mu.RUnlock()
+
+	var cfg jobConfig
+	err := config.Decode(job.Config(), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config for job %s: %w", job.Name(), err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = runnerCfg.Timeout
+	}
+
+	name := containerName(job.Name(), r.seq.Add(1))
+
+	// the CLI needs the belt's environment to find the runtime, and is given extra time to exit after the container
+	// is removed
+	cliRunner := local.NewRunner()
+	err = cliRunner.Configure(map[string]any{
+		"timeout":        (timeout + removeTimeout).String(),
+		"inheritEnv":     true,
+		"maxOutputBytes": runnerCfg.MaxOutputBytes,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	env := make(map[string]any, len(cfg.Env))
+	for k, v := range cfg.Env {
+		env[k] = v
+	}
+
+	return &runContainer{
+		name:    name,
+		cli:     runnerCfg.CLI,
+		timeout: timeout,
+		runner:  cliRunner,
+		job: &cliJob{
+			name: job.Name(),
+			config: map[string]any{
+				"command": runnerCfg.CLI,
+				"args":    runArgs(name, cfg),
+				"env":     env,
+			},
+		},
+	}, nil
+}
+
+// remove force removes the container, errors are ignored as the container may have already exited. The container is
+// removed when the job's context has ended, so a new context limited to removeTimeout is used.
+func (c *runContainer) remove() {
+	ctx, cancel := context.WithTimeout(context.Background(), removeTimeout)
+	defer cancel()
+
+	_ = exec.CommandContext(ctx, c.cli, "rm", "--force", c.name).Run()
+}
+
+// runArgs returns the CLI arguments to run the job's container
+func runArgs(name string, cfg jobConfig) []string {
+	args := []string{"run", "--rm", "--name", name}
+
+	if cfg.Entrypoint != "" {
+		args = append(args, "--entrypoint", cfg.Entrypoint)
+	}
+	if cfg.Workdir != "" {
+		args = append(args, "--workdir", cfg.Workdir)
+	}
+	if cfg.User != "" {
+		args = append(args, "--user", cfg.User)
+	}
+	if cfg.Network != "" {
+		args = append(args, "--network", cfg.Network)
+	}
+	if cfg.CPUs > 0 {
+		args = append(args, "--cpus", strconv.FormatFloat(cfg.CPUs, 'f', -1, 64))
+	}
+	if cfg.Memory != "" {
+		args = append(args, "--memory", cfg.Memory)
+	}
+
+	for _, mount := range cfg.Mounts {
+		args = append(args, "--volume", mount)
+	}
+
+	// values are read from the CLI's environment
+	keys := make([]string, 0, len(cfg.Env))
+	for k := range cfg.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		args = append(args, "--env", k)
+	}
+
+	args = append(args, cfg.Image)
+
+	return append(args, cfg.Command...)
+}
+
+var containerNameReplacer = regexp.MustCompile(`[^a-zA-Z0-9_.-]+`)
+
+// containerName returns a unique name for a job's container
+func containerName(jobName string, seq int64) string {
+	return fmt.Sprintf("toolbelt-%s-%d-%d", containerNameReplacer.ReplaceAllString(jobName, "-"), time.Now().Unix(), seq)
+}
+
+// cliJob is the external job run by the local runner to invoke the CLI
+type cliJob struct {
+	name   string
+	config map[string]any
+}
+
+func (j *cliJob) Name() string {
+	return j.name
+}
+
+func (j *cliJob) RunnerName() string {
+	return local.RunnerName
+}
+
+func (j *cliJob) Config() map[string]any {
+	return j.config
+}
@@ -0,0 +1,167 @@
+package container_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/runners/container"
+	"github.com/charlieegan3/toolbelt/pkg/runners/local"
+)
+
+// fakeCLI records its arguments and behaves like a container runtime CLI running a container which prints GREETING.
+// Containers with a command of fail or sleep exit with an error or run until they are killed.
+const fakeCLI = `#!/bin/sh
+echo "$*" >> "$FAKE_CLI_LOG"
+if [ "$1" = "rm" ]; then
+  exit 0
+fi
+case "$*" in
+  *" fail") echo "container failed" >&2; exit 125 ;;
+  *" sleep") exec sleep 10 ;;
+esac
+echo "GREETING=$GREETING"
+`
+
+type externalJob struct {
+	config map[string]any
+}
+
+func (e *externalJob) Name() string { return "test job" }
+
+func (e *externalJob) RunnerName() string { return container.RunnerName }
+
+func (e *externalJob) Config() map[string]any { return e.config }
+
+// newRunner returns a runner using the fake CLI and a function to read the CLI invocations
+func newRunner(t *testing.T) (*container.Runner, func() []string) {
+	dir := t.TempDir()
+
+	cli := filepath.Join(dir, "fake-cli")
+	require.NoError(t, os.WriteFile(cli, []byte(fakeCLI), 0o755))
+
+	log := filepath.Join(dir, "cli.log")
+	t.Setenv("FAKE_CLI_LOG", log)
+
+	runner := container.NewRunner()
+	require.NoError(t, runner.Configure(map[string]any{"cli": cli}))
+
+	return runner, func() []string {
+		data, err := os.ReadFile(log)
+		require.NoError(t, err)
+		return strings.Split(strings.TrimSpace(string(data)), "\n")
+	}
+}
+
+func TestRunner(t *testing.T) {
+	t.Run("maps config to arguments", func(t *testing.T) {
+		runner, invocations := newRunner(t)
+
+		result, err := runner.Run(context.Background(), &externalJob{config: map[string]any{
+			"image":      "alpine:3",
+			"entrypoint": "/bin/sh",
+			"command":    []any{"-c", "echo hi"},
+			"env":        map[string]any{"GREETING": "hello", "A": "b"},
+			"mounts":     []any{"/srv/data:/data:ro", "/tmp:/tmp"},
+			"workdir":    "/data",
+			"user":       "1000",
+			"network":    "none",
+			"cpus":       0.5,
+			"memory":     "256m",
+		}})
+		require.NoError(t, err)
+		require.Equal(t, "GREETING=hello\n", result.Stdout)
+
+		calls := invocations()
+		require.Len(t, calls, 1)
+		require.Regexp(t, `^run --rm --name toolbelt-test-job-\d+-\d+ `, calls[0])
+		require.True(t, strings.HasSuffix(calls[0], strings.Join([]string{
+			"--entrypoint /bin/sh",
+			"--workdir /data",
+			"--user 1000",
+			"--network none",
+			"--cpus 0.5",
+			"--memory 256m",
+			"--volume /srv/data:/data:ro",
+			"--volume /tmp:/tmp",
+			"--env A",
+			"--env GREETING",
+			"alpine:3 -c echo hi",
+		}, " ")), calls[0])
+	})
+
+	t.Run("non-zero exit", func(t *testing.T) {
+		runner, _ := newRunner(t)
+
+		_, err := runner.Run(context.Background(), &externalJob{config: map[string]any{
+			"image":   "alpine:3",
+			"command": []any{"fail"},
+		}})
+
+		var exitErr *local.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		require.Equal(t, 125, exitErr.ExitCode)
+		require.ErrorContains(t, err, "exited with code 125: container failed")
+	})
+
+	t.Run("timeout removes the container", func(t *testing.T) {
+		runner, invocations := newRunner(t)
+
+		_, err := runner.Run(context.Background(), &externalJob{config: map[string]any{
+			"image":   "alpine:3",
+			"command": []any{"sleep"},
+			"timeout": "200ms",
+		}})
+
+		var exitErr *local.ExitError
+		require.True(t, errors.As(err, &exitErr))
+		require.True(t, exitErr.TimedOut)
+
+		// the container has been removed by the time Run returns
+		calls := invocations()
+		require.Len(t, calls, 2)
+		require.True(t, strings.HasPrefix(calls[1], "rm --force toolbelt-test-job-"))
+	})
+
+	t.Run("cancel removes the container", func(t *testing.T) {
+		runner, invocations := newRunner(t)
+
+		handle, err := runner.StartJob(context.Background(), &externalJob{config: map[string]any{
+			"image":   "alpine:3",
+			"command": []any{"sleep"},
+		}})
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(handle.ID(), "toolbelt-test-job-"))
+
+		// wait for the CLI to start running the container
+		require.Eventually(t, func() bool {
+			_, err := os.Stat(os.Getenv("FAKE_CLI_LOG"))
+			return err == nil
+		}, 5*time.Second, 10*time.Millisecond)
+
+		require.NoError(t, handle.Cancel(context.Background()))
+
+		require.Eventually(t, func() bool {
+			status, err := handle.Status(context.Background())
+			return err == nil && status == apis.ExternalJobFailed
+		}, 5*time.Second, 10*time.Millisecond)
+
+		calls := invocations()
+		require.Len(t, calls, 2)
+		require.Equal(t, "rm --force "+handle.ID(), calls[1])
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		runner, _ := newRunner(t)
+
+		_, err := runner.Run(context.Background(), &externalJob{config: map[string]any{"command": []any{"true"}}})
+		require.ErrorContains(t, err, "invalid config for job test job")
+	})
+}
@@ -0,0 +1,95 @@
+package local
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// StartJob starts the job's process and returns without waiting for it to exit. The process is not tied to ctx, it
+// runs until it exits, reaches its timeout or is cancelled with the handle.
+func (r *Runner) StartJob(ctx context.Context, job apis.ExternalJob) (apis.ExternalJobHandle, error) {
+	p, err := r.newProcess(context.Background(), job)
+	if err != nil {
+		return nil, err
+	}
+
+	// stdout and stderr are combined so that the logs are in the order they were written
+	output := &limitedBuffer{limit: p.maxOutput}
+	p.cmd.Stdout = output
+	p.cmd.Stderr = output
+
+	start := time.Now()
+	err = p.start()
+	if err != nil {
+		p.cancel()
+		return nil, p.exitError(job.Name(), Result{ExitCode: -1}, err)
+	}
+
+	h := &handle{
+		id:     fmt.Sprintf("%s-%d", job.Name(), r.seq.Add(1)),
+		cancel: p.cancel,
+		output: output,
+		status: apis.ExternalJobRunning,
+	}
+
+	go func() {
+		defer p.cancel()
+
+		err := p.wait()
+
+		result := Result{
+			Stdout:   output.String(),
+			ExitCode: p.cmd.ProcessState.ExitCode(),
+			Duration: time.Since(start),
+		}
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.err = p.exitError(job.Name(), result, err)
+		h.status = apis.ExternalJobSucceeded
+		if h.err != nil {
+			h.status = apis.ExternalJobFailed
+		}
+	}()
+
+	return h, nil
+}
+
+// handle follows a job started with StartJob
+type handle struct {
+	id     string
+	cancel context.CancelFunc
+	output *limitedBuffer
+
+	mu     sync.Mutex
+	status apis.ExternalJobStatus
+	err    error
+}
+
+func (h *handle) ID() string {
+	return h.id
+}
+
+func (h *handle) Status(ctx context.Context) (apis.ExternalJobStatus, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.status, nil
+}
+
+// Logs returns the combined stdout and stderr of the job so far
+func (h *handle) Logs(ctx context.Context) (string, error) {
+	return h.output.String(), nil
+}
+
+// Cancel kills the job's process
+func (h *handle) Cancel(ctx context.Context) error {
+	h.cancel()
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package local_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/runners/local"
+)
+
+func TestRunnerStartJob(t *testing.T) {
+	ctx := context.Background()
+	runner := local.NewRunner()
+
+	waitForStatus := func(t *testing.T, handle apis.ExternalJobHandle, expected apis.ExternalJobStatus) {
+		require.Eventually(t, func() bool {
+			status, err := handle.Status(ctx)
+			return err == nil && status == expected
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	t.Run("succeeds with logs", func(t *testing.T) {
+		handle, err := runner.StartJob(ctx, &externalJob{config: map[string]any{
+			"command": "sh",
+			"args":    []any{"-c", "echo out; echo err >&2"},
+		}})
+		require.NoError(t, err)
+		require.NotEmpty(t, handle.ID())
+
+		waitForStatus(t, handle, apis.ExternalJobSucceeded)
+
+		logs, err := handle.Logs(ctx)
+		require.NoError(t, err)
+		require.Equal(t, "out\nerr\n", logs)
+	})
+
+	t.Run("fails on non-zero exit", func(t *testing.T) {
+		handle, err := runner.StartJob(ctx, &externalJob{config: map[string]any{
+			"command": "sh",
+			"args":    []any{"-c", "exit 1"},
+		}})
+		require.NoError(t, err)
+
+		waitForStatus(t, handle, apis.ExternalJobFailed)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		// the shell's child process must be killed too, or the job does not finish until it exits
+		handle, err := runner.StartJob(ctx, &externalJob{config: map[string]any{
+			"command": "sh",
+			"args":    []any{"-c", "sleep 10; echo done"},
+		}})
+		require.NoError(t, err)
+
+		status, err := handle.Status(ctx)
+		require.NoError(t, err)
+		require.Equal(t, apis.ExternalJobRunning, status)
+
+		require.NoError(t, handle.Cancel(ctx))
+
+		waitForStatus(t, handle, apis.ExternalJobFailed)
+	})
+
+	t.Run("start error", func(t *testing.T) {
+		_, err := runner.StartJob(ctx, &externalJob{config: map[string]any{
+			"command": "toolbelt-command-which-does-not-exist",
+		}})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,16 @@
+//go:build !unix
+
+package local
+
+import (
+	"os/exec"
+)
+
+// setProcessGroup is a no-op where process groups are not supported
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// killProcessGroup kills the command's process, processes it started are not killed where process groups are not
+// supported
+func killProcessGroup(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+}
@@ -0,0 +1,330 @@
+// Package local provides an apis.ExternalJobRunner which runs external jobs as processes on the belt's host. This is
+// useful for running jobs which need other binaries, and for trying out external jobs without a remote runner.
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/config"
+)
+
+// RunnerName is the name jobs use to select the local runner
+const RunnerName = "local"
+
+// runnerConfig is the config of the runner, set with Configure
+type runnerConfig struct {
+	// Timeout is used for jobs which don't set their own timeout
+	Timeout time.Duration `config:"timeout" default:"1h" validate:"min=1s"`
+	// InheritEnv passes the belt's environment to jobs, variables set by jobs take precedence
+	InheritEnv bool `config:"inheritEnv"`
+	// MaxOutputBytes limits how much of each of stdout and stderr is kept
+	MaxOutputBytes int `config:"maxOutputBytes" default:"1048576" validate:"min=1"`
+}
+
+// jobConfig is the config of a job, taken from the job's Config
+type jobConfig struct {
+	Command string            `config:"command,required" validate:"nonempty"`
+	Args    []string          `config:"args"`
+	Env     map[string]string `config:"env"`
+	Dir     string            `config:"dir"`
+	Timeout time.Duration     `config:"timeout"`
+}
+
+// Runner runs external jobs as local processes. The job's Config sets the process to run:
+//
+//	command: /usr/bin/rsync
+//	args: [-a, /data, /backup]
+//	env:
+//	  RSYNC_PASSWORD: secret
+//	dir: /tmp
+//	timeout: 10m
+type Runner struct {
+	mu     sync.RWMutex
+	config runnerConfig
+
+	// seq numbers the jobs started with StartJob
+	seq atomic.Int64
+}
+
+// NewRunner returns a runner using the default config
+func NewRunner() *Runner {
+	r := &Runner{}
+
+	// the defaults always decode
+	_ = r.Configure(nil)
+
+	return r
+}
+
+func (r *Runner) Name() string {
+	return RunnerName
+}
+
+// Configure sets the runner's config, keys which are not set use their defaults
+func (r *Runner) Configure(cfg map[string]any) error {
+	var c runnerConfig
+	err := config.Decode(cfg, &c)
+	if err != nil {
+		return fmt.Errorf("failed to configure %s runner: %w", RunnerName, err)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.config = c
+
+	return nil
+}
+
+// RunJob runs the job and waits for it to exit, an *ExitError is returned if the job fails
+func (r *Runner) RunJob(job apis.ExternalJob) error {
+	_, err := r.Run(context.Background(), job)
+
+	return err
+}
+
+// Result is the output of a job which ran to completion
+type Result struct {
+	Stdout   string
+	Stderr   string
+	ExitCode int
+	Duration time.Duration
+}
+
+// ExitError is returned when a job's process could not be started, exits with a non-zero code or is killed when it
+// times out
+type ExitError struct {
+	JobName string
+	Result
+
+	// TimedOut is set when the process was killed after reaching its timeout
+	TimedOut bool
+	// Err is the underlying error from starting or waiting for the process
+	Err error
+}
+
+func (e *ExitError) Error() string {
+	var message string
+	switch {
+	case e.TimedOut:
+		message = fmt.Sprintf("job %s timed out after %s", e.JobName, e.Duration.Round(time.Millisecond))
+	case e.ExitCode > 0:
+		message = fmt.Sprintf("job %s exited with code %d", e.JobName, e.ExitCode)
+	default:
+		message = fmt.Sprintf("job %s failed: %s", e.JobName, e.Err)
+	}
+
+	// the end of stderr usually explains the failure
+	if stderr := lastLine(e.Stderr); stderr != "" {
+		message = fmt.Sprintf("%s: %s", message, stderr)
+	}
+
+	return message
+}
+
+func (e *ExitError) Unwrap() error {
+	return e.Err
+}
+
+// Run runs the job, waiting for it to exit or for ctx to be cancelled. The output of the job is returned when the job
+// exits successfully, otherwise an *ExitError holding the output is returned.
+func (r *Runner) Run(ctx context.Context, job apis.ExternalJob) (Result, error) {
+	p, err := r.newProcess(ctx, job)
+	if err != nil {
+		return Result{}, err
+	}
+	defer p.cancel()
+
+	stdout := &limitedBuffer{limit: p.maxOutput}
+	stderr := &limitedBuffer{limit: p.maxOutput}
+	p.cmd.Stdout = stdout
+	p.cmd.Stderr = stderr
+
+	start := time.Now()
+	err = p.start()
+	if err == nil {
+		err = p.wait()
+	}
+
+	result := Result{
+		Stdout:   stdout.String(),
+		Stderr:   stderr.String(),
+		ExitCode: p.cmd.ProcessState.ExitCode(),
+		Duration: time.Since(start),
+	}
+
+	return result, p.exitError(job.Name(), result, err)
+}
+
+// process is a job's process which is ready to start
+type process struct {
+	cmd       *exec.Cmd
+	ctx       context.Context
+	cancel    context.CancelFunc
+	maxOutput int
+
+	// exited is closed once the process has been waited for
+	exited chan struct{}
+}
+
+// start starts the process and kills it, along with any processes it started, when its context is done. Killing only
+// the process would leave its children holding its output open, and waiting for the process would block until they
+// exit.
+func (p *process) start() error {
+	err := p.cmd.Start()
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		select {
+		case <-p.ctx.Done():
+			// the context is cancelled once a finished process has been waited for too
+			select {
+			case <-p.exited:
+			default:
+				killProcessGroup(p.cmd)
+			}
+		case <-p.exited:
+		}
+	}()
+
+	return nil
+}
+
+// wait waits for a started process to exit
+func (p *process) wait() error {
+	defer close(p.exited)
+
+	return p.cmd.Wait()
+}
+
+// newProcess builds the process for a job from its config, the process is killed when ctx is done or when the job's
+// timeout is reached
+func (r *Runner) newProcess(ctx context.Context, job apis.ExternalJob) (*process, error) {
+	r.mu.RLock()
+	runnerCfg := r.config
+	r.mu.RUnlock()
+
+	var cfg jobConfig
+	err := config.Decode(job.Config(), &cfg)
+	if err != nil {
+		return nil, fmt.Errorf("invalid config for job %s: %w", job.Name(), err)
+	}
+
+	timeout := cfg.Timeout
+	if timeout <= 0 {
+		timeout = runnerCfg.Timeout
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+
+	cmd := exec.Command(cfg.Command, cfg.Args...)
+	cmd.Dir = cfg.Dir
+	cmd.Env = jobEnv(cfg.Env, runnerCfg.InheritEnv)
+	// the process is started in its own group so that it can be killed along with its children
+	setProcessGroup(cmd)
+
+	return &process{
+		cmd:       cmd,
+		ctx:       ctx,
+		cancel:    cancel,
+		maxOutput: runnerCfg.MaxOutputBytes,
+		exited:    make(chan struct{}),
+	}, nil
+}
+
+// exitError returns an *ExitError for the error returned when running the process, or nil if the process succeeded
+func (p *process) exitError(jobName string, result Result, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	exitErr := &ExitError{
+		JobName:  jobName,
+		Result:   result,
+		TimedOut: errors.Is(p.ctx.Err(), context.DeadlineExceeded),
+		Err:      err,
+	}
+	if exitErr.TimedOut {
+		exitErr.Err = p.ctx.Err()
+	}
+
+	return exitErr
+}
+
+// jobEnv returns the environment for a job's process, in a stable order
+func jobEnv(env map[string]string, inherit bool) []string {
+	// an empty environment must be non-nil, otherwise the process inherits the belt's environment
+	vars := []string{}
+	if inherit {
+		vars = os.Environ()
+	}
+
+	keys := make([]string, 0, len(env))
+	for k := range env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// later values take precedence when the process looks up a variable
+	for _, k := range keys {
+		vars = append(vars, fmt.Sprintf("%s=%s", k, env[k]))
+	}
+
+	return vars
+}
+
+func lastLine(s string) string {
+	lines := strings.Split(strings.TrimSpace(s), "\n")
+
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// limitedBuffer keeps up to limit bytes written to it and discards the rest, it can be read while being written to
+type limitedBuffer struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	remaining := b.limit - b.buf.Len()
+	if remaining < len(p) {
+		b.truncated = true
+		if remaining > 0 {
+			b.buf.Write(p[:remaining])
+		}
+
+		// the full length is reported so that the process isn't sent an error
+		return len(p), nil
+	}
+
+	return b.buf.Write(p)
+}
+
+func (b *limitedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.truncated {
+		return b.buf.String() + "\n[truncated]"
+	}
+
+	return b.buf.String()
+}
@@ -0,0 +1,153 @@
+package local_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/runners/local"
+)
+
+type externalJob struct {
+	config map[string]any
+}
+
+func (e *externalJob) Name() string { return "test-job" }
+
+func (e *externalJob) RunnerName() string { return local.RunnerName }
+
+func (e *externalJob) Config() map[string]any { return e.config }
+
+func TestRunner(t *testing.T) {
+	t.Setenv("TOOLBELT_LOCAL_RUNNER_TEST", "inherited")
+
+	dir := t.TempDir()
+
+	testCases := map[string]struct {
+		runnerConfig map[string]any
+		jobConfig    map[string]any
+
+		expectedStdout string
+		expectedError  string
+		checkError     func(t *testing.T, exitErr *local.ExitError)
+	}{
+		"args and env": {
+			jobConfig: map[string]any{
+				"command": "sh",
+				"args":    []any{"-c", `echo "$GREETING $1"`, "sh", "world"},
+				"env":     map[string]any{"GREETING": "hello"},
+			},
+			expectedStdout: "hello world\n",
+		},
+		"working dir": {
+			jobConfig: map[string]any{
+				"command": "pwd",
+				"dir":     dir,
+			},
+			expectedStdout: dir + "\n",
+		},
+		"env is not inherited by default": {
+			jobConfig: map[string]any{
+				"command": "sh",
+				"args":    []any{"-c", `echo "[$TOOLBELT_LOCAL_RUNNER_TEST]"`},
+			},
+			expectedStdout: "[]\n",
+		},
+		"env is inherited": {
+			runnerConfig: map[string]any{"inheritEnv": true},
+			jobConfig: map[string]any{
+				"command": "sh",
+				"args":    []any{"-c", `echo "[$TOOLBELT_LOCAL_RUNNER_TEST]"`},
+			},
+			expectedStdout: "[inherited]\n",
+		},
+		"output is limited": {
+			runnerConfig: map[string]any{"maxOutputBytes": 5},
+			jobConfig: map[string]any{
+				"command": "echo",
+				"args":    []any{"hello world"},
+			},
+			expectedStdout: "hello\n[truncated]",
+		},
+		"non-zero exit": {
+			jobConfig: map[string]any{
+				"command": "sh",
+				"args":    []any{"-c", "echo partial; echo something went wrong >&2; exit 3"},
+			},
+			expectedStdout: "partial\n",
+			expectedError:  "job test-job exited with code 3: something went wrong",
+			checkError: func(t *testing.T, exitErr *local.ExitError) {
+				require.Equal(t, 3, exitErr.ExitCode)
+				require.Equal(t, "something went wrong\n", exitErr.Stderr)
+				require.False(t, exitErr.TimedOut)
+			},
+		},
+		"timeout": {
+			jobConfig: map[string]any{
+				"command": "sleep",
+				"args":    []any{"10"},
+				"timeout": "100ms",
+			},
+			checkError: func(t *testing.T, exitErr *local.ExitError) {
+				require.True(t, exitErr.TimedOut)
+				require.ErrorIs(t, exitErr, context.DeadlineExceeded)
+				require.Less(t, exitErr.Duration, 5*time.Second)
+			},
+		},
+		"timeout with child processes": {
+			jobConfig: map[string]any{
+				"command": "sh",
+				"args":    []any{"-c", "sleep 10; echo done"},
+				"timeout": "100ms",
+			},
+			checkError: func(t *testing.T, exitErr *local.ExitError) {
+				require.True(t, exitErr.TimedOut)
+				require.Less(t, exitErr.Duration, 5*time.Second)
+			},
+		},
+		"missing command": {
+			jobConfig:     map[string]any{"command": "toolbelt-command-which-does-not-exist"},
+			expectedError: "job test-job failed",
+			checkError: func(t *testing.T, exitErr *local.ExitError) {
+				require.ErrorIs(t, exitErr, exec.ErrNotFound)
+			},
+		},
+		"invalid config": {
+			jobConfig:     map[string]any{"args": []any{"hello"}},
+			expectedError: "invalid config for job test-job",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			runner := local.NewRunner()
+			if testCase.runnerConfig != nil {
+				require.NoError(t, runner.Configure(testCase.runnerConfig))
+			}
+
+			result, err := runner.Run(context.Background(), &externalJob{config: testCase.jobConfig})
+			require.Equal(t, testCase.expectedStdout, result.Stdout)
+
+			if testCase.expectedError == "" && testCase.checkError == nil {
+				require.NoError(t, err)
+				require.Equal(t, 0, result.ExitCode)
+				return
+			}
+
+			require.Error(t, err)
+			if testCase.expectedError != "" {
+				require.ErrorContains(t, err, testCase.expectedError)
+			}
+
+			if testCase.checkError != nil {
+				var exitErr *local.ExitError
+				require.True(t, errors.As(err, &exitErr))
+				testCase.checkError(t, exitErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package secrets
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// FileProvider resolves secrets from the contents of files, e.g. secret://file/run/secrets/token. Trailing newlines
+// are removed from the file contents.
+type FileProvider struct {
+	// Dir is the directory secret paths are relative to, paths are treated as absolute when unset. Paths which lead
+	// outside of Dir are rejected.
+	Dir string
+}
+
+func (f *FileProvider) Name() string {
+	return "file"
+}
+
+func (f *FileProvider) Resolve(ctx context.Context, path string) (string, error) {
+	dir := f.Dir
+	if dir == "" {
+		dir = "/"
+	}
+
+	file := filepath.Join(dir, path)
+
+	rel, err := filepath.Rel(dir, file)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("secret file %s is outside of %s", path, dir)
+	}
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read secret file: %w", err)
+	}
+
+	return strings.TrimRight(string(data), "\r\n"), nil
+}
+
+// EnvProvider resolves secrets from environment variables, e.g. secret://env/API_TOKEN
+type EnvProvider struct{}
+
+func (e *EnvProvider) Name() string {
+	return "env"
+}
+
+func (e *EnvProvider) Resolve(ctx context.Context, path string) (string, error) {
+	value, ok := os.LookupEnv(path)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", path)
+	}
+
+	return value, nil
+}
@@ -0,0 +1,84 @@
+package secrets
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Redacted replaces secret values in redacted strings
+const Redacted = "[redacted]"
+
+// Redactor removes known secret values from strings before they are logged or displayed
+type Redactor struct {
+	mu       sync.RWMutex
+	values   map[string]struct{}
+	replacer *strings.Replacer
+}
+
+// Add records a secret value to be redacted
+func (r *Redactor) Add(value string) {
+	if value == "" {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.values == nil {
+		r.values = make(map[string]struct{})
+	}
+	if _, ok := r.values[value]; ok {
+		return
+	}
+	r.values[value] = struct{}{}
+
+	// longer values are replaced first so that a secret containing another secret is fully redacted
+	values := make([]string, 0, len(r.values))
+	for v := range r.values {
+		values = append(values, v)
+	}
+	sort.Slice(values, func(i, j int) bool { return len(values[i]) > len(values[j]) })
+
+	var pairs []string
+	for _, v := range values {
+		pairs = append(pairs, v, Redacted)
+	}
+	r.replacer = strings.NewReplacer(pairs...)
+}
+
+// Redact returns s with any secret values replaced
+func (r *Redactor) Redact(s string) string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if r.replacer == nil {
+		return s
+	}
+
+	return r.replacer.Replace(s)
+}
+
+// RedactError returns err with any secret values replaced in its message. The original error can still be matched
+// with errors.Is and errors.As.
+func (r *Redactor) RedactError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &redactedError{message: r.Redact(err.Error()), err: err}
+}
+
+// redactedError is an error with secret values removed from its message
+type redactedError struct {
+	message string
+	err     error
+}
+
+func (e *redactedError) Error() string {
+	return e.message
+}
+
+func (e *redactedError) Unwrap() error {
+	return e.err
+}
@@ -0,0 +1,283 @@
+package tool
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	utilshttp "github.com/charlieegan3/toolbelt/pkg/utils/http"
+)
+
+// AdminTool is a built-in tool which serves information about the tools registered on a belt. It is added to a belt
+// like any other tool.
+//
+// The admin tool has no authentication of its own and can run any of the belt's jobs, so it must only be mounted
+// behind authentication, such as a proxy, or on a server which is not reachable by untrusted clients. Requests to run
+// jobs are protected against cross site request forgery by requiring either the X-Toolbelt-Admin header, which
+// browsers don't send cross site without a preflight that the admin tool doesn't allow, or the token embedded in the
+// admin page's forms.
+type AdminTool struct {
+	belt *Belt
+	path string
+
+	// csrfToken is embedded in the forms of the admin page and required when they are submitted
+	csrfToken string
+}
+
+// adminRequestHeader is the header which API clients send to run jobs instead of the admin page's token
+const adminRequestHeader = "X-Toolbelt-Admin"
+
+// NewAdminTool returns an admin tool for the belt, mounted at path
+func NewAdminTool(belt *Belt, path string) *AdminTool {
+	return &AdminTool{
+		belt:      belt,
+		path:      path,
+		csrfToken: newCSRFToken(),
+	}
+}
+
+// newCSRFToken returns a random token for the admin page's forms
+func newCSRFToken() string {
+	token := make([]byte, 32)
+
+	_, err := rand.Read(token)
+	if err != nil {
+		panic(fmt.Sprintf("failed to generate admin CSRF token: %s", err))
+	}
+
+	return hex.EncodeToString(token)
+}
+
+func (a *AdminTool) Name() string {
+	return "toolbelt-admin"
+}
+
+func (a *AdminTool) FeatureSet() apis.FeatureSet {
+	return apis.FeatureSet{
+		HTTP: true,
+	}
+}
+
+func (a *AdminTool) HTTPPath() string {
+	return a.path
+}
+
+func (a *AdminTool) HTTPHost() string {
+	return ""
+}
+
+// SetConfig is a no-op for this tool
+func (a *AdminTool) SetConfig(config map[string]any) error {
+	return nil
+}
+
+func (a *AdminTool) HTTPAttach(router *mux.Router) error {
+	router.HandleFunc("", utilshttp.BuildRedirectHandler(a.HTTPPath()+"/")).Methods("GET")
+
+	router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		page := adminPage{
+			Tools:        a.belt.Inspect(request.Context()),
+			ExternalJobs: a.belt.ExternalJobs(request.Context()),
+			CSRFToken:    a.csrfToken,
+		}
+
+		writer.Header().Set("Content-Type", "text/html; charset=utf-8")
+		err := adminTemplate.Execute(writer, page)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+	}).Methods("GET")
+
+	router.HandleFunc("/tools.json", func(writer http.ResponseWriter, request *http.Request) {
+		infos := a.belt.Inspect(request.Context())
+
+		writer.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(writer).Encode(infos)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+	}).Methods("GET")
+
+	router.HandleFunc("/external-jobs.json", func(writer http.ResponseWriter, request *http.Request) {
+		infos := a.belt.ExternalJobs(request.Context())
+
+		writer.Header().Set("Content-Type", "application/json")
+		err := json.NewEncoder(writer).Encode(infos)
+		if err != nil {
+			writer.WriteHeader(http.StatusInternalServerError)
+		}
+	}).Methods("GET")
+
+	// jobs are run to completion and the run is returned, or with ?async=true the run is started in the background and
+	// the request returns straight away
+	router.HandleFunc("/jobs/{tool}/{job}/run", func(writer http.ResponseWriter, request *http.Request) {
+		if !a.isTrustedRequest(request) {
+			writeAdminError(
+				writer, http.StatusForbidden,
+				"requests to run jobs must set the "+adminRequestHeader+" header or be sent from the admin page",
+			)
+			return
+		}
+
+		vars := mux.Vars(request)
+
+		async := false
+		if value := request.URL.Query().Get("async"); value != "" {
+			var err error
+			async, err = strconv.ParseBool(value)
+			if err != nil {
+				writeAdminError(writer, http.StatusBadRequest, "async must be true or false")
+				return
+			}
+		}
+
+		if async {
+			err := a.belt.StartJobNow(vars["tool"], vars["job"])
+			if err != nil {
+				writeAdminError(writer, runJobErrorStatus(err), a.belt.redactor.Redact(err.Error()))
+				return
+			}
+
+			writeAdminJSON(writer, http.StatusAccepted, jobRunStarted{
+				ToolName: vars["tool"],
+				JobName:  vars["job"],
+				Status:   "started",
+			})
+			return
+		}
+
+		// the run is not tied to the request, so that it isn't cancelled if the client goes away
+		run, err := a.belt.RunJobNow(context.Background(), vars["tool"], vars["job"])
+		if err != nil {
+			writeAdminError(writer, runJobErrorStatus(err), a.belt.redactor.Redact(err.Error()))
+			return
+		}
+
+		writeAdminJSON(writer, http.StatusOK, run)
+	}).Methods("POST")
+
+	return nil
+}
+
+// isTrustedRequest returns true if the request to change the belt's state was sent with the admin request header or
+// from a form on the admin page, rather than from another site
+func (a *AdminTool) isTrustedRequest(request *http.Request) bool {
+	if request.Header.Get(adminRequestHeader) != "" {
+		return true
+	}
+
+	token := request.PostFormValue("csrf_token")
+
+	return token != "" && subtle.ConstantTimeCompare([]byte(token), []byte(a.csrfToken)) == 1
+}
+
+// runJobErrorStatus returns the status code for an error running a job
+func runJobErrorStatus(err error) int {
+	if errors.Is(err, ErrJobNotFound) {
+		return http.StatusNotFound
+	}
+
+	return http.StatusInternalServerError
+}
+
+// writeAdminError writes a plain text error response
+func writeAdminError(writer http.ResponseWriter, status int, message string) {
+	writer.WriteHeader(status)
+
+	_, err := writer.Write([]byte(message))
+	if err != nil {
+		log.Printf("failed to write admin response: %v", err)
+	}
+}
+
+// writeAdminJSON writes value as a JSON response
+func writeAdminJSON(writer http.ResponseWriter, status int, value any) {
+	writer.Header().Set("Content-Type", "application/json")
+	writer.WriteHeader(status)
+
+	err := json.NewEncoder(writer).Encode(value)
+	if err != nil {
+		log.Printf("failed to write admin response: %v", err)
+	}
+}
+
+// jobRunStarted is the response to a request to run a job in the background, the outcome of the run is recorded in
+// the job run history
+type jobRunStarted struct {
+	ToolName string `json:"tool_name"`
+	JobName  string `json:"job_name"`
+	Status   string `json:"status"`
+}
+
+// adminPage is the data used to render the admin page
+type adminPage struct {
+	Tools        []ToolInfo
+	ExternalJobs []ExternalJobInfo
+	CSRFToken    string
+}
+
+var adminTemplate = template.Must(template.New("admin").Funcs(template.FuncMap{"join": strings.Join}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>toolbelt</title>
+</head>
+<body>
+  <h1>toolbelt</h1>
+  {{ range .Tools }}
+  <h2>{{ .Name }}</h2>
+  <ul>
+    <li>features: {{ with .FeatureSet }}config={{ .Config }} database={{ .Database }} http={{ .HTTP }} http_host={{ .HTTPHost }} tcp={{ .TCP }} jobs={{ .Jobs }} external_jobs={{ .ExternalJobs }} lifecycle={{ .Lifecycle }} health={{ .Health }} events={{ .Events }} queue={{ .Queue }}{{ end }}</li>
+    {{ if .HTTPPath }}<li>path: /{{ .HTTPPath }}</li>{{ end }}
+    {{ if .HTTPHost }}<li>host: {{ .HTTPHost }}</li>{{ end }}
+    {{ with .Migrations }}<li>migration version: {{ .Version }}{{ if .Dirty }} (dirty){{ end }}{{ if .Error }} {{ .Error }}{{ end }}</li>{{ end }}
+  </ul>
+  {{ if .Jobs }}
+  <table>
+    <tr><th>job</th><th>schedule</th><th>events</th><th>timeout</th><th>next run</th><th></th></tr>
+    {{ $tool := .Name }}
+    {{ range .Jobs }}
+    <tr>
+      <td>{{ .Name }}</td>
+      <td>{{ .Schedule }}</td>
+      <td>{{ join .Events ", " }}</td>
+      <td>{{ .Timeout }}</td>
+      <td>{{ if .NextRun }}{{ .NextRun.Format "2006-01-02 15:04:05 MST" }}{{ else }}{{ .ScheduleError }}{{ end }}</td>
+      <td><form method="post" action="jobs/{{ $tool }}/{{ .Name }}/run"><input type="hidden" name="csrf_token" value="{{ $.CSRFToken }}"><button type="submit">run now</button></form></td>
+    </tr>
+    {{ end }}
+  </table>
+  {{ end }}
+  {{ end }}
+  {{ if .ExternalJobs }}
+  <h2>external jobs</h2>
+  <table>
+    <tr><th>id</th><th>tool</th><th>job</th><th>runner</th><th>status</th><th>started</th><th>finished</th></tr>
+    {{ range .ExternalJobs }}
+    <tr>
+      <td>{{ .ID }}</td>
+      <td>{{ .ToolName }}</td>
+      <td>{{ .JobName }}</td>
+      <td>{{ .Runner }}</td>
+      <td>{{ .Status }}{{ if .StatusError }} ({{ .StatusError }}){{ end }}</td>
+      <td>{{ .StartedAt.Format "2006-01-02 15:04:05 MST" }}</td>
+      <td>{{ if .FinishedAt }}{{ .FinishedAt.Format "2006-01-02 15:04:05 MST" }}{{ end }}</td>
+    </tr>
+    {{ end }}
+  </table>
+  {{ end }}
+</body>
+</html>
+`))
@@ -0,0 +1,160 @@
+package tool_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/example"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+func TestAdminTool(t *testing.T) {
+	tb := tool.NewBelt()
+
+	count := 0
+	err := tb.AddTool(context.Background(), &example.JobsTool{Count: &count})
+	require.NoError(t, err)
+
+	err = tb.AddTool(context.Background(), &example.HelloWorld{})
+	require.NoError(t, err)
+
+	err = tb.AddTool(context.Background(), tool.NewAdminTool(tb, "admin"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/tools.json", nil)
+	rec := httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var infos []tool.ToolInfo
+	err = json.NewDecoder(rec.Body).Decode(&infos)
+	require.NoError(t, err)
+
+	require.Len(t, infos, 3)
+	require.Equal(t, "jobs", infos[0].Name)
+	require.Len(t, infos[0].Jobs, 1)
+	require.Equal(t, "example-job", infos[0].Jobs[0].Name)
+	require.NotNil(t, infos[0].Jobs[0].NextRun)
+	require.Equal(t, "example-hello-world", infos[1].HTTPPath)
+
+	req = httptest.NewRequest(http.MethodGet, "/admin/", nil)
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Contains(t, rec.Body.String(), "example-job")
+}
+
+func TestAdminToolRunJobNow(t *testing.T) {
+	tb := tool.NewBelt()
+
+	count := 0
+	err := tb.AddTool(context.Background(), &example.JobsTool{Count: &count})
+	require.NoError(t, err)
+
+	err = tb.AddTool(context.Background(), tool.NewAdminTool(tb, "admin"))
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/jobs/jobs/example-job/run", nil)
+	req.Header.Set("X-Toolbelt-Admin", "true")
+	rec := httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var run tool.JobRun
+	err = json.NewDecoder(rec.Body).Decode(&run)
+	require.NoError(t, err)
+
+	require.Equal(t, tool.JobOutcomeSucceeded, run.Outcome)
+	require.Equal(t, "example-job", run.JobName)
+	require.False(t, run.FinishedAt.IsZero())
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/jobs/jobs/example-job/run?async=true", nil)
+	req.Header.Set("X-Toolbelt-Admin", "true")
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusAccepted, rec.Code)
+
+	var started map[string]string
+	err = json.NewDecoder(rec.Body).Decode(&started)
+	require.NoError(t, err)
+
+	require.Equal(t, "example-job", started["job_name"])
+	require.Equal(t, "started", started["status"])
+
+	// the async run is started in the background, so its outcome is read from the job metrics
+	require.Eventually(t, func() bool {
+		var buf bytes.Buffer
+		err := tb.Metrics().WriteText(&buf)
+		require.NoError(t, err)
+
+		return strings.Contains(
+			buf.String(),
+			`toolbelt_job_runs_total{tool="jobs",job="example-job",outcome="succeeded"} 2`,
+		)
+	}, 5*time.Second, 10*time.Millisecond)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/jobs/jobs/example-job/run?async=maybe", nil)
+	req.Header.Set("X-Toolbelt-Admin", "true")
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	req = httptest.NewRequest(http.MethodPost, "/admin/jobs/jobs/missing/run", nil)
+	req.Header.Set("X-Toolbelt-Admin", "true")
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusNotFound, rec.Code)
+}
+
+func TestAdminToolRunJobNowCSRF(t *testing.T) {
+	tb := tool.NewBelt()
+
+	count := 0
+	err := tb.AddTool(context.Background(), &example.JobsTool{Count: &count})
+	require.NoError(t, err)
+
+	err = tb.AddTool(context.Background(), tool.NewAdminTool(tb, "admin"))
+	require.NoError(t, err)
+
+	// a form submitted from another site has neither the header nor the token
+	req := httptest.NewRequest(http.MethodPost, "/admin/jobs/jobs/example-job/run", strings.NewReader("csrf_token=guess"))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec := httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusForbidden, rec.Code)
+	require.Equal(t, 0, count)
+
+	// the admin page's form includes the token
+	req = httptest.NewRequest(http.MethodGet, "/admin/", nil)
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	match := regexp.MustCompile(`name="csrf_token" value="([0-9a-f]+)"`).FindStringSubmatch(rec.Body.String())
+	require.Len(t, match, 2)
+
+	req = httptest.NewRequest(
+		http.MethodPost, "/admin/jobs/jobs/example-job/run", strings.NewReader("csrf_token="+match[1]),
+	)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+	require.Equal(t, 1, count)
+}
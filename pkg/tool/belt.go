@@ -3,20 +3,21 @@ package tool
 import (
 	"context"
 	"database/sql"
+	"embed"
 	"fmt"
-	"log"
+	"net"
 	"net/http"
 	"strings"
-	"time"
+	"sync"
+	"sync/atomic"
 
-	"github.com/Jeffail/gabs/v2"
 	"github.com/golang-migrate/migrate/v4"
 	"github.com/golang-migrate/migrate/v4/database/postgres"
 	"github.com/golang-migrate/migrate/v4/source/iofs"
 	"github.com/gorilla/mux"
-	"github.com/robfig/cron"
 
 	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/secrets"
 	utilsHTTP "github.com/charlieegan3/toolbelt/pkg/utils/http"
 )
 
@@ -24,26 +25,110 @@ import (
 type Belt struct {
 	Router *mux.Router
 
-	server *http.Server
+	// serverMu guards server, which is set while the belt's HTTP server is running
+	serverMu sync.Mutex
+	server   *Server
 
-	config map[string]any
+	// configMu guards config, which can be replaced while the belt is running by ReloadConfig
+	configMu sync.RWMutex
+	config   map[string]any
+
+	// reloadMu makes sure that only one config reload runs at a time
+	reloadMu sync.Mutex
 
 	db *sql.DB
 
+	// instance identifies this belt when several belts share a database
+	instance string
+
+	// dbMigrateMu guards dbMigrated, which is set once the belt's own schema has been migrated
+	dbMigrateMu sync.Mutex
+	dbMigrated  bool
+
+	// tools holds each tool added to the belt in registration order
+	tools []apis.Tool
+
+	// ready is set while the belt is serving requests and is reported by the readiness endpoint
+	ready atomic.Bool
+
+	// startedTools holds the tools which have been started with StartTools and must be stopped on shutdown
+	startedTools []startedTool
+
 	jobs map[string][]apis.Job
 
+	// runningMu guards running, which holds the job runs in progress
+	runningMu sync.Mutex
+	running   map[*runningJob]struct{}
+
+	// eventSubscriptions holds the jobs subscribed to each event name
+	eventSubscriptions map[string][]eventSubscription
+	// eventRunsMu guards eventRuns and eventRunsCtx, which are set while RunJobs is running so that runs triggered by
+	// events are tracked and drained with the scheduled runs
+	eventRunsMu  sync.Mutex
+	eventRuns    *scheduledRuns
+	eventRunsCtx context.Context
+
+	// queueHandlers holds the task handlers of each tool using the queue feature, by tool name and then task kind
+	queueHandlers map[string]map[string]apis.TaskHandler
+	// taskNotify wakes a waiting task worker when a task is enqueued
+	taskNotify chan struct{}
+
+	// jobWorkers limits the number of jobs running at once
+	jobWorkers *jobWorkers
+
+	metrics *beltMetrics
+
 	externalJobRunners map[string]apis.ExternalJobRunner
+
+	// externalJobsMu guards externalJobs, which holds the external jobs started by tools with StartExternalJob
+	externalJobsMu sync.Mutex
+	externalJobs   []*trackedExternalJob
+	// externalJobSeq numbers jobs started on runners which don't return their own IDs
+	externalJobSeq atomic.Int64
+
+	secretProviders map[string]apis.SecretProvider
+
+	// redactor holds the values of resolved secrets so that they can be removed from logs and admin output
+	redactor secrets.Redactor
 }
 
-// NewBelt creates a new Belt struct with an initalized router
+// NewBelt creates a new Belt struct with an initalized router. The router serves the belt's health endpoints on
+// /healthz and /readyz, and its metrics on the path set with metrics.path.
 func NewBelt() *Belt {
 	r := mux.NewRouter()
-	r.Use(utilsHTTP.InitMiddlewareLogging())
 
-	return &Belt{
-		Router: r,
-		jobs:   make(map[string][]apis.Job),
+	b := &Belt{
+		Router:        r,
+		jobs:          make(map[string][]apis.Job),
+		queueHandlers: make(map[string]map[string]apis.TaskHandler),
+		taskNotify:    make(chan struct{}, 1),
+		metrics:       newBeltMetrics(),
+		instance:      instanceName(),
+		secretProviders: map[string]apis.SecretProvider{
+			"file": &secrets.FileProvider{},
+			"env":  &secrets.EnvProvider{},
+		},
 	}
+
+	b.jobWorkers = newJobWorkers(b.jobWorkerLimits)
+
+	logging := utilsHTTP.InitMiddlewareLogging()
+	r.Use(logging)
+	r.Use(b.metricsMiddleware)
+
+	// mux doesn't run middleware for requests which match no route, so its handlers for them are wrapped here
+	r.NotFoundHandler = logging(b.metricsMiddleware(http.NotFoundHandler()))
+	r.MethodNotAllowedHandler = logging(b.metricsMiddleware(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+		},
+	)))
+
+	r.HandleFunc("/healthz", b.handleHealth).Methods("GET")
+	r.HandleFunc("/readyz", b.handleReady).Methods("GET")
+	r.MatcherFunc(b.matchMetricsPath).Methods("GET").Handler(b.metrics.registry)
+
+	return b
 }
 
 // AddExternalJobRunner adds a new external job runner to the belt. Jobs can be run using this runner by referencing the runner's name
@@ -63,19 +148,34 @@ func (b *Belt) ExternalJobsFunc() func(job apis.ExternalJob) error {
 			return fmt.Errorf("failed to find runner %s", job.RunnerName())
 		}
 
-		return runner.RunJob(job)
+		// jobs are configured from tool config, so secret values may appear in the runner's errors
+		return b.redactor.RedactError(runner.RunJob(job))
 	}
 }
 
-// AddTool adds a new tool to the belt. Each tool is given a subrouter with the base path set to the tool's HTTPPath
+// AddTool adds a new tool to the belt. Each tool is given a subrouter with the base path set to the tool's HTTPPath.
+// Tools using the lifecycle or TCP features are not started until StartTools or Run is called. Secret values are
+// redacted from the returned error, since a tool's errors may include its config.
 func (b *Belt) AddTool(ctx context.Context, tool apis.Tool) error {
+	return b.redactor.RedactError(b.addTool(ctx, tool))
+}
+
+// addTool adds a tool to the belt, see AddTool
+func (b *Belt) addTool(ctx context.Context, tool apis.Tool) error {
+	if b.db != nil {
+		err := b.beltDatabaseMigrate(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to prepare belt database: %w", err)
+		}
+	}
+
 	if tool.FeatureSet().Config {
-		toolConfig, ok := b.config[tool.Name()]
-		if !ok {
-			return fmt.Errorf("tool %s requires config but none was provided", tool.Name())
+		toolConfig, err := b.toolConfig(ctx, tool)
+		if err != nil {
+			return err
 		}
 
-		err := tool.SetConfig(toolConfig.(map[string]any))
+		err = tool.SetConfig(toolConfig)
 		if err != nil {
 			return fmt.Errorf("failed to set config for tool %s: %w", tool.Name(), err)
 		}
@@ -87,39 +187,14 @@ func (b *Belt) AddTool(ctx context.Context, tool apis.Tool) error {
 			return fmt.Errorf("tool %s requires a database but none was provided", tool.Name())
 		}
 
-		// open a connection to the database for this set of migrations, using postgres.WithInstance seems to leak
-		// connections to we use postgres.WithConnection instead
-		conn, err := b.db.Conn(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to get database connection to migrate tool %s: %w", tool.Name(), err)
-		}
-		defer conn.Close()
-
 		migrations, path, err := databaseTool.DatabaseMigrations()
 		if err != nil {
 			return fmt.Errorf("failed to get database migrations for tool %s: %w", tool.Name(), err)
 		}
 
-		driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
-			MigrationsTable: fmt.Sprintf("schema_migrations_%s", strings.ReplaceAll(tool.Name(), "-", "_")),
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create database driver for tool %s: %w", tool.Name(), err)
-		}
-
-		source, err := iofs.New(migrations, path)
-		if err != nil {
-			return fmt.Errorf("failed to create database source for tool %s: %w", tool.Name(), err)
-		}
-
-		m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+		err = b.databaseMigrate(ctx, tool.Name(), migrations, path)
 		if err != nil {
-			return fmt.Errorf("failed to create database migrate instance for tool %s: %w", tool.Name(), err)
-		}
-
-		err = m.Up()
-		if err != nil && err != migrate.ErrNoChange {
-			return fmt.Errorf("failed to run database migrations for tool %s: %w", tool.Name(), err)
+			return fmt.Errorf("failed to migrate database for tool %s: %w", tool.Name(), err)
 		}
 
 		databaseTool.DatabaseSet(b.db)
@@ -141,20 +216,13 @@ func (b *Belt) AddTool(ctx context.Context, tool apis.Tool) error {
 			}
 			toolRouter = b.Router.PathPrefix(fmt.Sprintf("/%s", path)).Subrouter()
 		}
+		toolRouter.Use(metricsToolMiddleware(tool.Name()))
 		err := httpTool.HTTPAttach(toolRouter)
 		if err != nil {
 			return fmt.Errorf("failed to attach tool: %v", err)
 		}
 	}
 
-	tcpTool, ok := tool.(apis.TCPTool)
-	if tool.FeatureSet().TCP && ok {
-		err := tcpTool.TCPStart(ctx)
-		if err != nil {
-			return fmt.Errorf("failed to start TCP service for tool: %v", err)
-		}
-	}
-
 	// this needs to happen early so that the runners are available
 	// for jobs in the next step
 	externalJobsTool, ok := tool.(apis.ExternalJobsTool)
@@ -162,6 +230,26 @@ func (b *Belt) AddTool(ctx context.Context, tool apis.Tool) error {
 		externalJobsTool.ExternalJobsFuncSet(b.ExternalJobsFunc())
 	}
 
+	asyncExternalJobsTool, ok := tool.(apis.AsyncExternalJobsTool)
+	if tool.FeatureSet().ExternalJobs && ok {
+		asyncExternalJobsTool.ExternalJobsStartFuncSet(b.ExternalJobsStartFunc(tool.Name()))
+	}
+
+	queueTool, ok := tool.(apis.QueueTool)
+	if tool.FeatureSet().Queue && ok {
+		if b.db == nil {
+			return fmt.Errorf("tool %s requires a database for the task queue but none was provided", tool.Name())
+		}
+
+		b.queueHandlers[tool.Name()] = queueTool.QueueHandlers()
+		queueTool.QueueFuncSet(b.EnqueueFunc(tool.Name()))
+	}
+
+	eventsTool, ok := tool.(apis.EventsTool)
+	if tool.FeatureSet().Events && ok {
+		eventsTool.EventsFuncSet(b.EventsFunc(tool.Name()))
+	}
+
 	jobsTool, ok := tool.(apis.JobsTool)
 	if tool.FeatureSet().Jobs && ok {
 		loadedJobs, err := jobsTool.Jobs()
@@ -169,21 +257,78 @@ func (b *Belt) AddTool(ctx context.Context, tool apis.Tool) error {
 			return fmt.Errorf("failed to get jobs for tool %s: %w", tool.Name(), err)
 		}
 		for _, job := range loadedJobs {
-			b.AddJob(tool.Name(), job)
+			err = b.AddJob(tool.Name(), job)
+			if err != nil {
+				return err
+			}
 		}
 	}
 
+	b.tools = append(b.tools, tool)
+
 	return nil
 }
 
 func (b *Belt) SetConfig(config map[string]any) {
+	b.configMu.Lock()
+	defer b.configMu.Unlock()
+
 	b.config = config
 }
 
+// getConfig returns the current belt config, which may be replaced when config is reloaded
+func (b *Belt) getConfig() map[string]any {
+	b.configMu.RLock()
+	defer b.configMu.RUnlock()
+
+	return b.config
+}
+
 func (b *Belt) SetDatabase(db *sql.DB) {
 	b.db = db
 }
 
+// databaseMigrate runs the up migrations in the supplied filesystem, recording the applied version in a migrations
+// table derived from name
+func (b *Belt) databaseMigrate(ctx context.Context, name string, migrations *embed.FS, path string) error {
+	// open a connection to the database for this set of migrations, using postgres.WithInstance seems to leak
+	// connections to we use postgres.WithConnection instead
+	conn, err := b.db.Conn(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to get database connection: %w", err)
+	}
+	defer conn.Close()
+
+	driver, err := postgres.WithConnection(ctx, conn, &postgres.Config{
+		MigrationsTable: migrationsTable(name),
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create database driver: %w", err)
+	}
+
+	source, err := iofs.New(migrations, path)
+	if err != nil {
+		return fmt.Errorf("failed to create database source: %w", err)
+	}
+
+	m, err := migrate.NewWithInstance("iofs", source, "postgres", driver)
+	if err != nil {
+		return fmt.Errorf("failed to create database migrate instance: %w", err)
+	}
+
+	err = m.Up()
+	if err != nil && err != migrate.ErrNoChange {
+		return fmt.Errorf("failed to run database migrations: %w", err)
+	}
+
+	return nil
+}
+
+// migrationsTable returns the name of the table used to track the migrations for the named tool
+func migrationsTable(name string) string {
+	return fmt.Sprintf("schema_migrations_%s", strings.ReplaceAll(name, "-", "_"))
+}
+
 func (b *Belt) DatabaseDownMigrate(tool apis.DatabaseTool) error {
 	if b.db == nil {
 		return fmt.Errorf("tool requires a database but none was provided")
@@ -216,126 +361,23 @@ func (b *Belt) DatabaseDownMigrate(tool apis.DatabaseTool) error {
 	return nil
 }
 
-func (b *Belt) RunServer(ctx context.Context, host, port string) {
-	var path string
-
-	config := gabs.Wrap(b.config)
-
-	readTimeout := 30 * time.Second
-	path = "server.timeout.read"
-	readTimeoutString, ok := config.Path(path).Data().(string)
-	if ok {
-		duration, err := time.ParseDuration(readTimeoutString)
-		if err == nil {
-			readTimeout = duration
-		}
-	}
-
-	writeTimeout := 30 * time.Second
-	path = "server.timeout.write"
-	writeTimeoutString, ok := config.Path(path).Data().(string)
-	if ok {
-		duration, err := time.ParseDuration(writeTimeoutString)
-		if err == nil {
-			writeTimeout = duration
-		}
-	}
-
-	b.server = &http.Server{
-		Handler:      b.Router,
-		Addr:         fmt.Sprintf("%s:%s", host, port),
-		WriteTimeout: writeTimeout,
-		ReadTimeout:  readTimeout,
-	}
-
-	go func() {
-		err := b.server.ListenAndServe()
-		if err != nil {
-			log.Println(err)
-		}
-	}()
-
-	<-ctx.Done()
-
-	log.Println("Shutting down server")
-
-	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
-	defer cancel()
-
-	if err := b.server.Shutdown(ctx); err != nil {
-		log.Fatalf("Graceful shutdown failed: %s", err)
+// RunServer starts the belt's tools and serves HTTP requests on host and port until ctx is cancelled, then shuts down
+// the server and stops the tools. Jobs are not run, use Run to run the whole belt.
+//
+// An error is returned straight away if the tools fail to start or the server can't listen on host and port. Errors
+// serving requests, shutting down the server or stopping the tools are returned once the server has stopped.
+func (b *Belt) RunServer(ctx context.Context, host, port string) error {
+	err := b.StartTools(ctx)
+	if err != nil {
+		return err
 	}
-	log.Println("Server gracefully stopped")
-}
 
-func (b *Belt) AddJob(toolName string, job apis.Job) {
-	if _, ok := b.jobs[toolName]; !ok {
-		b.jobs[toolName] = []apis.Job{}
-	}
+	deadline := b.newShutdownDeadline()
 
-	b.jobs[toolName] = append(b.jobs[toolName], job)
-}
-
-func (b *Belt) RunJobs(ctx context.Context) {
-	crn := cron.New()
-
-	for toolName, jobs := range b.jobs {
-		for i := range jobs {
-			job := b.jobs[toolName][i]
-
-			jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
-
-			log.Printf("loaded job %q with schedule %q", jobRef, job.Schedule())
-
-			err := crn.AddFunc(
-				job.Schedule(),
-				func() {
-					log.Printf("running job %q", jobRef)
-					jobCtx, cancel := context.WithTimeout(ctx, job.Timeout())
-					defer cancel()
-
-					doneCh := make(chan error, 1)
-					panicCh := make(chan interface{}, 1)
-
-					go func() {
-						defer func() {
-							if p := recover(); p != nil {
-								panicCh <- p
-							}
-						}()
-
-						doneCh <- job.Run(jobCtx)
-					}()
-
-					select {
-					case err := <-doneCh:
-						if err != nil {
-							log.Printf("error running job %q: %v", jobRef, err)
-						} else {
-							log.Printf("ran job %q", jobRef)
-						}
-					case p := <-panicCh:
-						log.Printf("error running job %q, panicked: %v", jobRef, p)
-					case <-ctx.Done():
-						if ctx.Err() == context.DeadlineExceeded {
-							log.Printf("parent context timed out during job %q", jobRef)
-						} else if ctx.Err() == context.Canceled {
-							log.Printf("parent context cancelled during job %q", jobRef)
-						}
-					}
-				},
-			)
-			if err != nil {
-				log.Printf("failed to add job %q to cron: %v", jobRef, err)
-			}
-		}
+	server, err := b.StartServer(net.JoinHostPort(host, port))
+	if err != nil {
+		return b.stopToolsAfter(err, deadline)
 	}
 
-	log.Printf("job worker started")
-	go func() {
-		crn.Start()
-	}()
-
-	log.Println("stopping job worker")
-	crn.Stop()
+	return b.stopToolsAfter(b.serveUntilDone(ctx, server, deadline), deadline)
 }
@@ -0,0 +1,145 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/config"
+)
+
+// toolConfig returns the tool's section of the belt config with any secret references resolved. When the tool uses
+// typed config, the section is also decoded and validated into the tool's config struct.
+func (b *Belt) toolConfig(ctx context.Context, tool apis.Tool) (map[string]any, error) {
+	var target any
+	if typedConfigTool, ok := tool.(apis.TypedConfigTool); ok {
+		target = typedConfigTool.ConfigStruct()
+	}
+
+	return b.decodeToolConfig(ctx, tool.Name(), b.getConfig(), target)
+}
+
+// decodeToolConfig returns the named tool's section of cfg with any secret references resolved. When target is set,
+// the section is also decoded and validated into it, and a missing section is treated as empty so that defaults
+// apply.
+func (b *Belt) decodeToolConfig(
+	ctx context.Context,
+	name string,
+	cfg map[string]any,
+	target any,
+) (map[string]any, error) {
+	rawToolConfig, ok := cfg[name]
+	if !ok {
+		if target == nil {
+			return nil, fmt.Errorf("tool %s requires config but none was provided", name)
+		}
+		rawToolConfig = map[string]any{}
+	}
+
+	if _, ok := rawToolConfig.(map[string]any); !ok {
+		return nil, fmt.Errorf("tool %s config must be a map, got %T", name, rawToolConfig)
+	}
+
+	resolvedToolConfig, err := b.resolveSecrets(ctx, name, rawToolConfig)
+	if err != nil {
+		return nil, err
+	}
+	toolConfig := resolvedToolConfig.(map[string]any)
+
+	if target != nil {
+		err := config.Decode(toolConfig, target)
+		if err != nil {
+			var validationErr *config.ValidationError
+			if errors.As(err, &validationErr) {
+				// problems can include the invalid value, which may have been a secret
+				for i := range validationErr.Problems {
+					validationErr.Problems[i].Message = b.redactor.Redact(validationErr.Problems[i].Message)
+				}
+
+				prefixedErr := &config.ValidationError{}
+				prefixedErr.Merge(name, validationErr)
+				return nil, prefixedErr
+			}
+
+			return nil, fmt.Errorf("failed to decode config for tool %s: %w", name, err)
+		}
+	}
+
+	return toolConfig, nil
+}
+
+// AddTools validates the config of all the tools before adding each of them to the belt in order. If any tool's
+// config is missing or invalid, no tools are added and a *config.ValidationError listing every problem is returned.
+func (b *Belt) AddTools(ctx context.Context, tools ...apis.Tool) error {
+	validationErr := &config.ValidationError{}
+
+	for _, tool := range tools {
+		if !tool.FeatureSet().Config {
+			continue
+		}
+
+		_, err := b.toolConfig(ctx, tool)
+		if err != nil {
+			addConfigError(validationErr, tool.Name(), err)
+		}
+	}
+
+	if err := validationErr.ErrorOrNil(); err != nil {
+		return err
+	}
+
+	for _, tool := range tools {
+		err := b.AddTool(ctx, tool)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// addConfigError adds the problems in err to validationErr, other errors are added as a problem with the tool's config
+func addConfigError(validationErr *config.ValidationError, name string, err error) {
+	var toolValidationErr *config.ValidationError
+	if errors.As(err, &toolValidationErr) {
+		validationErr.Merge("", toolValidationErr)
+		return
+	}
+
+	validationErr.Add(name, "%s", err)
+}
+
+// configInt returns the integer value at the path, numbers decoded from JSON config are float64
+func configInt(value *gabs.Container) int {
+	switch v := value.Data().(type) {
+	case int:
+		return v
+	case int64:
+		return int(v)
+	case uint64:
+		return int(v)
+	case float64:
+		return int(v)
+	default:
+		return 0
+	}
+}
+
+// configDuration returns the duration at the path, or fallback if the value is unset or not a valid duration string
+func configDuration(value *gabs.Container, fallback time.Duration) time.Duration {
+	s, ok := value.Data().(string)
+	if !ok {
+		return fallback
+	}
+
+	duration, err := time.ParseDuration(s)
+	if err != nil {
+		return fallback
+	}
+
+	return duration
+}
@@ -0,0 +1,41 @@
+package tool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/config"
+	"github.com/charlieegan3/toolbelt/pkg/example"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+func TestAddToolsValidatesAllConfig(t *testing.T) {
+	tb := tool.NewBelt()
+
+	tb.SetConfig(map[string]any{
+		"typed-config-tool": map[string]any{
+			"repeat": 20,
+		},
+	})
+
+	err := tb.AddTools(
+		context.Background(),
+		&example.ConfigTool{},
+		&example.TypedConfigTool{},
+		tool.NewAdminTool(tb, "admin"),
+	)
+
+	var validationErr *config.ValidationError
+	require.ErrorAs(t, err, &validationErr)
+
+	require.Equal(t, []config.Problem{
+		{Key: "config-tool", Message: "tool config-tool requires config but none was provided"},
+		{Key: "typed-config-tool.greeting", Message: "is required"},
+		{Key: "typed-config-tool.repeat", Message: "must be at most 10"},
+	}, validationErr.Problems)
+
+	// no tools are added when any config is invalid
+	require.Empty(t, tb.Inspect(context.Background()))
+}
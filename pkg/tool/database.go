@@ -0,0 +1,32 @@
+package tool
+
+import (
+	"context"
+	"embed"
+	"fmt"
+)
+
+//go:embed database/migrations
+var beltMigrations embed.FS
+
+// beltMigrationsName is used to name the migrations table for the belt's own schema
+const beltMigrationsName = "toolbelt"
+
+// beltDatabaseMigrate runs the migrations for the belt's own schema, this is only done once per belt
+func (b *Belt) beltDatabaseMigrate(ctx context.Context) error {
+	b.dbMigrateMu.Lock()
+	defer b.dbMigrateMu.Unlock()
+
+	if b.dbMigrated {
+		return nil
+	}
+
+	err := b.databaseMigrate(ctx, beltMigrationsName, &beltMigrations, "database/migrations")
+	if err != nil {
+		return fmt.Errorf("failed to migrate belt database: %w", err)
+	}
+
+	b.dbMigrated = true
+
+	return nil
+}
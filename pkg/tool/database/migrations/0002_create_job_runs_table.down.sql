@@ -0,0 +1 @@
+DROP TABLE IF EXISTS toolbelt.job_runs;
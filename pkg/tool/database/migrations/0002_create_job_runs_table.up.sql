@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS toolbelt.job_runs (
+   id bigserial PRIMARY KEY,
+   tool_name text NOT NULL,
+   job_name text NOT NULL,
+   started_at timestamptz NOT NULL,
+   finished_at timestamptz NOT NULL,
+   outcome text NOT NULL,
+   error text NOT NULL DEFAULT '',
+   panic text NOT NULL DEFAULT ''
+);
+
+CREATE INDEX IF NOT EXISTS job_runs_tool_job_started_at_idx
+   ON toolbelt.job_runs (tool_name, job_name, started_at DESC);
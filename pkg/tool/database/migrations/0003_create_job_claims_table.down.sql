@@ -0,0 +1,3 @@
+ALTER TABLE toolbelt.job_runs DROP COLUMN IF EXISTS instance;
+
+DROP TABLE IF EXISTS toolbelt.job_claims;
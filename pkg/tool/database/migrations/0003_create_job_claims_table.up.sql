@@ -0,0 +1,10 @@
+CREATE TABLE IF NOT EXISTS toolbelt.job_claims (
+   tool_name text NOT NULL,
+   job_name text NOT NULL,
+   scheduled_at timestamptz NOT NULL,
+   instance text NOT NULL,
+   claimed_at timestamptz NOT NULL DEFAULT now(),
+   PRIMARY KEY (tool_name, job_name, scheduled_at)
+);
+
+ALTER TABLE toolbelt.job_runs ADD COLUMN IF NOT EXISTS instance text NOT NULL DEFAULT '';
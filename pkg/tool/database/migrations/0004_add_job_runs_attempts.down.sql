@@ -0,0 +1 @@
+ALTER TABLE toolbelt.job_runs DROP COLUMN IF EXISTS attempts;
@@ -0,0 +1 @@
+ALTER TABLE toolbelt.job_runs ADD COLUMN IF NOT EXISTS attempts integer NOT NULL DEFAULT 1;
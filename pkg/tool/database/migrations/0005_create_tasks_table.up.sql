@@ -0,0 +1,20 @@
+CREATE TABLE IF NOT EXISTS toolbelt.tasks (
+   id bigserial PRIMARY KEY,
+   tool_name text NOT NULL,
+   kind text NOT NULL,
+   payload jsonb NOT NULL DEFAULT 'null',
+   dedupe_key text,
+   status text NOT NULL DEFAULT 'pending',
+   attempts integer NOT NULL DEFAULT 0,
+   max_attempts integer NOT NULL,
+   run_at timestamptz NOT NULL DEFAULT now(),
+   last_error text NOT NULL DEFAULT '',
+   created_at timestamptz NOT NULL DEFAULT now(),
+   updated_at timestamptz NOT NULL DEFAULT now()
+);
+
+CREATE INDEX IF NOT EXISTS tasks_pending_run_at_idx
+   ON toolbelt.tasks (run_at, id) WHERE status = 'pending';
+
+CREATE UNIQUE INDEX IF NOT EXISTS tasks_pending_dedupe_key_idx
+   ON toolbelt.tasks (tool_name, kind, dedupe_key) WHERE status = 'pending' AND dedupe_key IS NOT NULL;
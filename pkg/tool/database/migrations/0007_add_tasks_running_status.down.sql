@@ -0,0 +1,16 @@
+-- running tasks are returned to pending, unless a pending task with the same dedupe key has since been enqueued
+DELETE FROM toolbelt.tasks running
+WHERE running.status = 'running' AND running.dedupe_key IS NOT NULL AND EXISTS (
+   SELECT 1 FROM toolbelt.tasks pending
+   WHERE pending.status = 'pending'
+      AND pending.tool_name = running.tool_name
+      AND pending.kind = running.kind
+      AND pending.dedupe_key = running.dedupe_key
+);
+
+UPDATE toolbelt.tasks SET status = 'pending' WHERE status = 'running';
+
+DROP INDEX IF EXISTS toolbelt.tasks_queued_run_at_idx;
+
+CREATE INDEX IF NOT EXISTS tasks_pending_run_at_idx
+   ON toolbelt.tasks (run_at, id) WHERE status = 'pending';
@@ -0,0 +1,5 @@
+-- claimed tasks are now marked as running, which excludes them from the pending dedupe index
+DROP INDEX IF EXISTS toolbelt.tasks_pending_run_at_idx;
+
+CREATE INDEX IF NOT EXISTS tasks_queued_run_at_idx
+   ON toolbelt.tasks (run_at, id) WHERE status IN ('pending', 'running');
@@ -0,0 +1,110 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// eventSubscription is a job which is run when an event is published
+type eventSubscription struct {
+	toolName string
+	job      apis.EventJob
+}
+
+// subscribeJob subscribes the job to each of its events
+func (b *Belt) subscribeJob(toolName string, job apis.EventJob) {
+	if b.eventSubscriptions == nil {
+		b.eventSubscriptions = make(map[string][]eventSubscription)
+	}
+
+	for _, name := range job.Events() {
+		b.eventSubscriptions[name] = append(b.eventSubscriptions[name], eventSubscription{toolName: toolName, job: job})
+	}
+}
+
+// ErrJobsNotRunning is returned when publishing an event while RunJobs is not running, since there is nothing to run
+// the jobs subscribed to it
+var ErrJobsNotRunning = errors.New("jobs are not running")
+
+// PublishEvent publishes an event to the belt's event bus. Jobs subscribed to the event are run in the background with
+// the event available from their context using apis.EventFromContext, PublishEvent does not wait for them.
+//
+// Events can only be published while RunJobs is running, the runs they trigger are cancelled and drained along with
+// the scheduled runs when RunJobs stops. An error wrapping ErrJobsNotRunning is returned otherwise.
+func (b *Belt) PublishEvent(event apis.Event) error {
+	if event.Name == "" {
+		return fmt.Errorf("failed to publish event from %q: event name is blank", event.Source)
+	}
+
+	b.eventRunsMu.Lock()
+	runs, ctx := b.eventRuns, b.eventRunsCtx
+	b.eventRunsMu.Unlock()
+
+	if runs == nil {
+		return fmt.Errorf("failed to publish event %q from %q: %w", event.Name, event.Source, ErrJobsNotRunning)
+	}
+
+	if event.PublishedAt.IsZero() {
+		event.PublishedAt = time.Now()
+	}
+
+	b.metrics.eventsPublished.Inc(event.Source, event.Name)
+
+	for _, sub := range b.eventSubscriptions[event.Name] {
+		// RunJobs may have started to stop since the runs were looked up
+		if !runs.start() {
+			return fmt.Errorf("failed to publish event %q from %q: %w", event.Name, event.Source, ErrJobsNotRunning)
+		}
+
+		go func(sub eventSubscription) {
+			defer runs.done()
+
+			b.runEventJob(ctx, sub.toolName, sub.job, event)
+		}(sub)
+	}
+
+	return nil
+}
+
+// setEventRuns sets the context and runs used for runs triggered by events, they are nil when RunJobs is not running
+func (b *Belt) setEventRuns(ctx context.Context, runs *scheduledRuns) {
+	b.eventRunsMu.Lock()
+	defer b.eventRunsMu.Unlock()
+
+	b.eventRuns = runs
+	b.eventRunsCtx = ctx
+}
+
+// EventsFunc returns a function which the named tool can use to publish events
+func (b *Belt) EventsFunc(source string) func(name string, payload any) error {
+	return func(name string, payload any) error {
+		return b.PublishEvent(apis.Event{
+			Name:    name,
+			Source:  source,
+			Payload: payload,
+		})
+	}
+}
+
+// runEventJob runs a job triggered by an event with the context of RunJobs' runs. Runs are not tied to the context of
+// the publisher, which has often ended by the time the job runs, but are cancelled if they are replaced under the job's
+// concurrency policy or when RunJobs stops.
+func (b *Belt) runEventJob(ctx context.Context, toolName string, job apis.Job, event apis.Event) {
+	jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+	ctx = apis.ContextWithEvent(ctx, event)
+
+	rj, _ := b.startJobRun(ctx, toolName, job)
+	if rj == nil {
+		return
+	}
+
+	log.Printf("event %q from %q triggered job %q", event.Name, event.Source, jobRef)
+
+	b.runTrackedJob(ctx, rj, toolName, job)
+}
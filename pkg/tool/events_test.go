@@ -0,0 +1,106 @@
+package tool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+// eventsTool publishes events with the function set by the belt, and has a job triggered by its events
+type eventsTool struct {
+	publish func(name string, payload any) error
+	job     *eventJob
+}
+
+func (e *eventsTool) Name() string { return "events-tool" }
+
+func (e *eventsTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Events: true, Jobs: true} }
+
+func (e *eventsTool) SetConfig(config map[string]any) error { return nil }
+
+func (e *eventsTool) EventsFuncSet(publish func(name string, payload any) error) { e.publish = publish }
+
+func (e *eventsTool) Jobs() ([]apis.Job, error) { return []apis.Job{e.job}, nil }
+
+// eventJob sends the event which triggered each run
+type eventJob struct {
+	events chan apis.Event
+}
+
+func (e *eventJob) Name() string { return "event-job" }
+
+func (e *eventJob) Run(ctx context.Context) error {
+	event, _ := apis.EventFromContext(ctx)
+	e.events <- event
+	return nil
+}
+
+func (e *eventJob) Timeout() time.Duration { return time.Second }
+
+func (e *eventJob) Schedule() string { return "" }
+
+func (e *eventJob) Events() []string { return []string{"webhook.received"} }
+
+func TestEvents(t *testing.T) {
+	tb := tool.NewBelt()
+
+	et := &eventsTool{job: &eventJob{events: make(chan apis.Event, 1)}}
+	err := tb.AddTool(context.Background(), et)
+	require.NoError(t, err)
+
+	require.NotNil(t, et.publish)
+
+	// events are rejected until RunJobs is running
+	require.ErrorIs(t, et.publish("webhook.received", nil), tool.ErrJobsNotRunning)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	errs := make(chan error, 1)
+	go func() {
+		errs <- tb.RunJobs(ctx)
+	}()
+
+	require.Eventually(t, func() bool {
+		return et.publish("something.else", nil) == nil
+	}, time.Second, 10*time.Millisecond)
+
+	// events without subscribers are dropped
+	require.NoError(t, et.publish("something.else", nil))
+	require.Error(t, et.publish("", nil))
+
+	err = et.publish("webhook.received", map[string]string{"id": "123"})
+	require.NoError(t, err)
+
+	select {
+	case event := <-et.job.events:
+		require.Equal(t, "webhook.received", event.Name)
+		require.Equal(t, "events-tool", event.Source)
+		require.Equal(t, map[string]string{"id": "123"}, event.Payload)
+		require.False(t, event.PublishedAt.IsZero())
+	case <-time.After(time.Second):
+		t.Fatal("event job was not run")
+	}
+
+	cancel()
+	select {
+	case err := <-errs:
+		require.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("RunJobs did not return after it was stopped")
+	}
+
+	// and once it has stopped
+	require.ErrorIs(t, et.publish("webhook.received", nil), tool.ErrJobsNotRunning)
+
+	infos := tb.Inspect(context.Background())
+	require.Len(t, infos, 1)
+	require.Equal(t, []string{"webhook.received"}, infos[0].Jobs[0].Events)
+	require.Nil(t, infos[0].Jobs[0].NextRun)
+	require.Empty(t, infos[0].Jobs[0].ScheduleError)
+}
@@ -0,0 +1,341 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/secrets"
+)
+
+// externalJobRetention is how long finished external jobs are listed by ExternalJobs
+const externalJobRetention = time.Hour
+
+// externalJobPollInterval is how often the status of unfinished external jobs is fetched from their runners, so that
+// the time jobs finish is recorded even when nothing else polls them
+const externalJobPollInterval = 30 * time.Second
+
+// ExternalJobInfo describes an external job started by a tool
+type ExternalJobInfo struct {
+	ID       string `json:"id"`
+	Runner   string `json:"runner"`
+	ToolName string `json:"tool_name"`
+	JobName  string `json:"job_name"`
+
+	Status apis.ExternalJobStatus `json:"status"`
+	// StatusError is set if the job's status could not be fetched from the runner
+	StatusError string `json:"status_error,omitempty"`
+
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at,omitempty"`
+}
+
+// trackedExternalJob wraps the handle returned by a runner so that the belt sees the job's status as the tool polls
+// it
+type trackedExternalJob struct {
+	handle apis.ExternalJobHandle
+
+	runner    string
+	toolName  string
+	jobName   string
+	startedAt time.Time
+
+	mu         sync.Mutex
+	status     apis.ExternalJobStatus
+	finishedAt time.Time
+}
+
+func (t *trackedExternalJob) ID() string {
+	return t.handle.ID()
+}
+
+func (t *trackedExternalJob) Status(ctx context.Context) (apis.ExternalJobStatus, error) {
+	status, err := t.handle.Status(ctx)
+	if err != nil {
+		return status, err
+	}
+
+	t.setStatus(status)
+
+	return status, nil
+}
+
+// setStatus records the job's status and the time it was first seen to have finished
+func (t *trackedExternalJob) setStatus(status apis.ExternalJobStatus) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.status = status
+	if status.Finished() && t.finishedAt.IsZero() {
+		t.finishedAt = time.Now()
+	}
+}
+
+// finished returns true if the job was last seen to have finished
+func (t *trackedExternalJob) finished() bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.status.Finished()
+}
+
+func (t *trackedExternalJob) Logs(ctx context.Context) (string, error) {
+	return t.handle.Logs(ctx)
+}
+
+func (t *trackedExternalJob) Cancel(ctx context.Context) error {
+	return t.handle.Cancel(ctx)
+}
+
+// finishedBefore returns true if the job finished before the given time
+func (t *trackedExternalJob) finishedBefore(before time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return !t.finishedAt.IsZero() && t.finishedAt.Before(before)
+}
+
+// ExternalJobsStartFunc returns a function which the named tool can use to start external jobs without waiting for
+// them to finish
+func (b *Belt) ExternalJobsStartFunc(
+	toolName string,
+) func(ctx context.Context, job apis.ExternalJob) (apis.ExternalJobHandle, error) {
+	return func(ctx context.Context, job apis.ExternalJob) (apis.ExternalJobHandle, error) {
+		return b.StartExternalJob(ctx, toolName, job)
+	}
+}
+
+// StartExternalJob starts an external job on the job's runner and returns a handle to follow its progress. Runners
+// which can't start jobs asynchronously run the job in the background, their handles can't fetch logs or cancel the
+// job. While RunJobs is running the belt polls the status of unfinished jobs in the background, each job is listed by
+// ExternalJobs until an hour after it was seen to finish.
+func (b *Belt) StartExternalJob(
+	ctx context.Context,
+	toolName string,
+	job apis.ExternalJob,
+) (apis.ExternalJobHandle, error) {
+	runner, ok := b.externalJobRunners[job.RunnerName()]
+	if !ok {
+		return nil, fmt.Errorf("failed to find runner %s", job.RunnerName())
+	}
+
+	var handle apis.ExternalJobHandle
+	if asyncRunner, ok := runner.(apis.AsyncExternalJobRunner); ok {
+		var err error
+		handle, err = asyncRunner.StartJob(ctx, job)
+		if err != nil {
+			// jobs are configured from tool config, so secret values may appear in the runner's errors
+			return nil, b.redactor.RedactError(
+				fmt.Errorf("failed to start external job %s on runner %s: %w", job.Name(), runner.Name(), err),
+			)
+		}
+	} else {
+		handle = startSyncExternalJob(
+			fmt.Sprintf("%s-%d", job.Name(), b.externalJobSeq.Add(1)), runner, job, &b.redactor,
+		)
+	}
+
+	tracked := &trackedExternalJob{
+		handle:    handle,
+		runner:    runner.Name(),
+		toolName:  toolName,
+		jobName:   job.Name(),
+		startedAt: time.Now(),
+		status:    apis.ExternalJobPending,
+	}
+
+	// jobs run in the background are recorded as finished as soon as they return, other jobs are polled
+	if syncHandle, ok := handle.(*syncExternalJobHandle); ok {
+		go func() {
+			<-syncHandle.done
+			tracked.setStatus(syncHandle.currentStatus())
+		}()
+	}
+
+	b.externalJobsMu.Lock()
+	b.externalJobs = append(b.pruneExternalJobs(), tracked)
+	b.externalJobsMu.Unlock()
+
+	log.Printf(
+		"started external job %q for tool %q on runner %q with id %q",
+		job.Name(), toolName, runner.Name(), handle.ID(),
+	)
+
+	return tracked, nil
+}
+
+// ExternalJobs returns the external jobs started by tools, oldest first. The status of unfinished jobs is fetched from
+// their runners.
+func (b *Belt) ExternalJobs(ctx context.Context) []ExternalJobInfo {
+	b.externalJobsMu.Lock()
+	b.externalJobs = b.pruneExternalJobs()
+	jobs := append([]*trackedExternalJob{}, b.externalJobs...)
+	b.externalJobsMu.Unlock()
+
+	infos := make([]ExternalJobInfo, 0, len(jobs))
+	for _, job := range jobs {
+		job.mu.Lock()
+		finished := job.status.Finished()
+		job.mu.Unlock()
+
+		var statusErr error
+		if !finished {
+			_, statusErr = job.Status(ctx)
+		}
+
+		job.mu.Lock()
+		info := ExternalJobInfo{
+			ID:        job.handle.ID(),
+			Runner:    job.runner,
+			ToolName:  job.toolName,
+			JobName:   job.jobName,
+			Status:    job.status,
+			StartedAt: job.startedAt,
+		}
+		if !job.finishedAt.IsZero() {
+			finishedAt := job.finishedAt
+			info.FinishedAt = &finishedAt
+		}
+		job.mu.Unlock()
+
+		if statusErr != nil {
+			info.StatusError = b.redactor.Redact(statusErr.Error())
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// pollExternalJobs fetches the status of unfinished external jobs from their runners and prunes the jobs past their
+// retention, until ctx is cancelled
+func (b *Belt) pollExternalJobs(ctx context.Context) {
+	ticker := time.NewTicker(externalJobPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		b.externalJobsMu.Lock()
+		jobs := append([]*trackedExternalJob{}, b.externalJobs...)
+		b.externalJobsMu.Unlock()
+
+		for _, job := range jobs {
+			if ctx.Err() != nil {
+				return
+			}
+			if job.finished() {
+				continue
+			}
+
+			statusCtx, cancel := context.WithTimeout(ctx, externalJobPollInterval)
+			_, err := job.Status(statusCtx)
+			cancel()
+			if err != nil && ctx.Err() == nil {
+				log.Printf(
+					"failed to fetch status of external job %q with id %q: %s",
+					job.jobName, job.handle.ID(), b.redactor.Redact(err.Error()),
+				)
+			}
+		}
+
+		b.externalJobsMu.Lock()
+		b.externalJobs = b.pruneExternalJobs()
+		b.externalJobsMu.Unlock()
+	}
+}
+
+// pruneExternalJobs returns the tracked external jobs without those which finished before the retention period,
+// b.externalJobsMu must be held
+func (b *Belt) pruneExternalJobs() []*trackedExternalJob {
+	cutoff := time.Now().Add(-externalJobRetention)
+
+	jobs := make([]*trackedExternalJob, 0, len(b.externalJobs))
+	for _, job := range b.externalJobs {
+		if !job.finishedBefore(cutoff) {
+			jobs = append(jobs, job)
+		}
+	}
+
+	return jobs
+}
+
+// syncExternalJobHandle runs a job on a runner which only supports RunJob in the background
+type syncExternalJobHandle struct {
+	id string
+
+	// redactor removes secrets from the job's error, which may include the job's config
+	redactor *secrets.Redactor
+
+	// done is closed when the job returns
+	done chan struct{}
+
+	mu     sync.Mutex
+	status apis.ExternalJobStatus
+	err    error
+}
+
+func startSyncExternalJob(
+	id string,
+	runner apis.ExternalJobRunner,
+	job apis.ExternalJob,
+	redactor *secrets.Redactor,
+) *syncExternalJobHandle {
+	h := &syncExternalJobHandle{id: id, redactor: redactor, done: make(chan struct{}), status: apis.ExternalJobRunning}
+
+	go func() {
+		defer close(h.done)
+
+		err := runner.RunJob(job)
+
+		h.mu.Lock()
+		defer h.mu.Unlock()
+
+		h.err = err
+		h.status = apis.ExternalJobSucceeded
+		if err != nil {
+			h.status = apis.ExternalJobFailed
+		}
+	}()
+
+	return h
+}
+
+func (h *syncExternalJobHandle) ID() string {
+	return h.id
+}
+
+func (h *syncExternalJobHandle) Status(ctx context.Context) (apis.ExternalJobStatus, error) {
+	return h.currentStatus(), nil
+}
+
+func (h *syncExternalJobHandle) currentStatus() apis.ExternalJobStatus {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	return h.status
+}
+
+// Logs returns the error from a failed job, runners which only support RunJob don't return the job's output
+func (h *syncExternalJobHandle) Logs(ctx context.Context) (string, error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if h.err != nil {
+		return h.redactor.Redact(h.err.Error()), nil
+	}
+
+	return "", nil
+}
+
+func (h *syncExternalJobHandle) Cancel(ctx context.Context) error {
+	return fmt.Errorf("external job %s can't be cancelled, its runner does not support cancellation", h.id)
+}
@@ -0,0 +1,125 @@
+package tool_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/runners/local"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+// syncRunner only supports running jobs to completion
+type syncRunner struct {
+	release chan struct{}
+}
+
+func (s *syncRunner) Name() string { return "sync" }
+
+func (s *syncRunner) Configure(config map[string]any) error { return nil }
+
+func (s *syncRunner) RunJob(job apis.ExternalJob) error {
+	<-s.release
+	return nil
+}
+
+type externalJob struct {
+	runner string
+	config map[string]any
+}
+
+func (e *externalJob) Name() string { return "external-job" }
+
+func (e *externalJob) RunnerName() string { return e.runner }
+
+func (e *externalJob) Config() map[string]any { return e.config }
+
+// externalJobsTool starts external jobs with the function set by the belt
+type externalJobsTool struct {
+	start func(ctx context.Context, job apis.ExternalJob) (apis.ExternalJobHandle, error)
+}
+
+func (e *externalJobsTool) Name() string { return "external-jobs-tool" }
+
+func (e *externalJobsTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{ExternalJobs: true} }
+
+func (e *externalJobsTool) SetConfig(config map[string]any) error { return nil }
+
+func (e *externalJobsTool) ExternalJobsStartFuncSet(
+	start func(ctx context.Context, job apis.ExternalJob) (apis.ExternalJobHandle, error),
+) {
+	e.start = start
+}
+
+func TestExternalJobHandles(t *testing.T) {
+	ctx := context.Background()
+
+	tb := tool.NewBelt()
+	tb.AddExternalJobRunner(local.NewRunner())
+
+	runner := &syncRunner{release: make(chan struct{})}
+	tb.AddExternalJobRunner(runner)
+
+	et := &externalJobsTool{}
+	require.NoError(t, tb.AddTool(ctx, et))
+	require.NoError(t, tb.AddTool(ctx, tool.NewAdminTool(tb, "admin")))
+
+	localHandle, err := et.start(ctx, &externalJob{
+		runner: local.RunnerName,
+		config: map[string]any{"command": "echo", "args": []any{"hello"}},
+	})
+	require.NoError(t, err)
+
+	syncHandle, err := et.start(ctx, &externalJob{runner: "sync"})
+	require.NoError(t, err)
+
+	_, err = et.start(ctx, &externalJob{runner: "missing"})
+	require.ErrorContains(t, err, "failed to find runner missing")
+
+	require.Eventually(t, func() bool {
+		status, err := localHandle.Status(ctx)
+		return err == nil && status == apis.ExternalJobSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+
+	logs, err := localHandle.Logs(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "hello\n", logs)
+
+	// the sync runner's job runs in the background and can't be cancelled
+	status, err := syncHandle.Status(ctx)
+	require.NoError(t, err)
+	require.Equal(t, apis.ExternalJobRunning, status)
+	require.Error(t, syncHandle.Cancel(ctx))
+
+	close(runner.release)
+	released := time.Now()
+
+	// the sync job is recorded as finished when it returns, rather than when it is next listed
+	time.Sleep(200 * time.Millisecond)
+
+	var infos []tool.ExternalJobInfo
+	require.Eventually(t, func() bool {
+		req := httptest.NewRequest(http.MethodGet, "/admin/external-jobs.json", nil)
+		rec := httptest.NewRecorder()
+		tb.Router.ServeHTTP(rec, req)
+
+		infos = nil
+		err := json.NewDecoder(rec.Body).Decode(&infos)
+		return err == nil && len(infos) == 2 && infos[1].Status == apis.ExternalJobSucceeded
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.Equal(t, localHandle.ID(), infos[0].ID)
+	require.Equal(t, "external-jobs-tool", infos[0].ToolName)
+	require.Equal(t, local.RunnerName, infos[0].Runner)
+	require.Equal(t, apis.ExternalJobSucceeded, infos[0].Status)
+	require.NotNil(t, infos[0].FinishedAt)
+	require.Equal(t, syncHandle.ID(), infos[1].ID)
+	require.NotNil(t, infos[1].FinishedAt)
+	require.WithinDuration(t, released, *infos[1].FinishedAt, 100*time.Millisecond)
+}
@@ -0,0 +1,146 @@
+package tool
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// healthCheckTimeout is the time allowed for all health checks to complete
+const healthCheckTimeout = 5 * time.Second
+
+// HealthStatus is the aggregated result of the belt's health checks
+type HealthStatus struct {
+	// Healthy is false if any critical check failed
+	Healthy bool `json:"healthy"`
+	// Ready is false until the belt is serving, and again once shutdown starts
+	Ready bool `json:"ready"`
+
+	Checks []HealthCheck `json:"checks"`
+}
+
+// HealthCheck is the result of a single health check
+type HealthCheck struct {
+	Name     string `json:"name"`
+	Healthy  bool   `json:"healthy"`
+	Critical bool   `json:"critical"`
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// CheckHealth runs the health check of the belt's database and each tool using the health feature. Tool checks are
+// critical unless the error returned wraps apis.ErrHealthNonCritical.
+func (b *Belt) CheckHealth(ctx context.Context) HealthStatus {
+	ctx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+	defer cancel()
+
+	type check struct {
+		name string
+		fn   func(ctx context.Context) error
+	}
+
+	var checks []check
+	if b.db != nil {
+		checks = append(checks, check{
+			name: "database",
+			fn: func(ctx context.Context) error {
+				err := b.db.PingContext(ctx)
+				if err != nil {
+					return fmt.Errorf("failed to ping database: %w", err)
+				}
+
+				return nil
+			},
+		})
+	}
+
+	for _, t := range b.tools {
+		healthTool, ok := t.(apis.HealthTool)
+		if t.FeatureSet().Health && ok {
+			checks = append(checks, check{name: t.Name(), fn: healthTool.HealthCheck})
+		}
+	}
+
+	status := HealthStatus{
+		Healthy: true,
+		Ready:   b.ready.Load(),
+		Checks:  make([]HealthCheck, len(checks)),
+	}
+
+	var wg sync.WaitGroup
+	for i := range checks {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			start := time.Now()
+			err := checks[i].fn(ctx)
+
+			result := HealthCheck{
+				Name:     checks[i].name,
+				Healthy:  err == nil,
+				Critical: !errors.Is(err, apis.ErrHealthNonCritical),
+				Duration: time.Since(start).String(),
+			}
+			if err != nil {
+				result.Error = b.redactor.Redact(err.Error())
+			}
+
+			status.Checks[i] = result
+		}(i)
+	}
+	wg.Wait()
+
+	for _, c := range status.Checks {
+		if !c.Healthy && c.Critical {
+			status.Healthy = false
+		}
+	}
+
+	return status
+}
+
+// handleHealth serves the belt's health status, responding with a 503 if a critical check fails
+func (b *Belt) handleHealth(writer http.ResponseWriter, request *http.Request) {
+	status := b.CheckHealth(request.Context())
+
+	writeHealthStatus(writer, status, status.Healthy)
+}
+
+// handleReady serves the belt's health status, responding with a 503 if a critical check fails or if the belt is not
+// ready to serve requests
+func (b *Belt) handleReady(writer http.ResponseWriter, request *http.Request) {
+	status := b.CheckHealth(request.Context())
+
+	writeHealthStatus(writer, status, status.Healthy && status.Ready)
+}
+
+// writeHealthStatus writes the status as JSON, it's encoded before the status code is written so that a failure to
+// encode can still be reported
+func writeHealthStatus(writer http.ResponseWriter, status HealthStatus, ok bool) {
+	var buf bytes.Buffer
+	err := json.NewEncoder(&buf).Encode(status)
+	if err != nil {
+		log.Printf("failed to encode health status: %v", err)
+		writer.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	writer.Header().Set("Content-Type", "application/json")
+	if !ok {
+		writer.WriteHeader(http.StatusServiceUnavailable)
+	}
+
+	_, err = writer.Write(buf.Bytes())
+	if err != nil {
+		log.Printf("failed to write health status: %v", err)
+	}
+}
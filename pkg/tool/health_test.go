@@ -0,0 +1,83 @@
+package tool_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+type healthTool struct {
+	name string
+	err  error
+}
+
+func (h *healthTool) Name() string { return h.name }
+
+func (h *healthTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Health: true} }
+
+func (h *healthTool) SetConfig(config map[string]any) error { return nil }
+
+func (h *healthTool) HealthCheck(ctx context.Context) error { return h.err }
+
+func TestHealthEndpoints(t *testing.T) {
+	testCases := map[string]struct {
+		tools          []apis.Tool
+		expectedStatus int
+	}{
+		"healthy": {
+			tools:          []apis.Tool{&healthTool{name: "a"}},
+			expectedStatus: http.StatusOK,
+		},
+		"non-critical failure": {
+			tools: []apis.Tool{
+				&healthTool{name: "a"},
+				&healthTool{name: "b", err: fmt.Errorf("slow upstream: %w", apis.ErrHealthNonCritical)},
+			},
+			expectedStatus: http.StatusOK,
+		},
+		"critical failure": {
+			tools: []apis.Tool{
+				&healthTool{name: "a"},
+				&healthTool{name: "b", err: fmt.Errorf("broken")},
+			},
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tb := tool.NewBelt()
+
+			for _, h := range testCase.tools {
+				err := tb.AddTool(context.Background(), h)
+				require.NoError(t, err)
+			}
+
+			req := httptest.NewRequest(http.MethodGet, "/healthz", nil)
+			rec := httptest.NewRecorder()
+			tb.Router.ServeHTTP(rec, req)
+
+			require.Equal(t, testCase.expectedStatus, rec.Code)
+
+			var status tool.HealthStatus
+			err := json.NewDecoder(rec.Body).Decode(&status)
+			require.NoError(t, err)
+			require.Len(t, status.Checks, len(testCase.tools))
+
+			// the belt is not serving, so is never ready
+			req = httptest.NewRequest(http.MethodGet, "/readyz", nil)
+			rec = httptest.NewRecorder()
+			tb.Router.ServeHTTP(rec, req)
+
+			require.Equal(t, http.StatusServiceUnavailable, rec.Code)
+		})
+	}
+}
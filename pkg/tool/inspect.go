@@ -0,0 +1,120 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// ToolInfo describes a tool registered on the belt
+type ToolInfo struct {
+	Name       string          `json:"name"`
+	FeatureSet apis.FeatureSet `json:"feature_set"`
+
+	HTTPPath string `json:"http_path,omitempty"`
+	HTTPHost string `json:"http_host,omitempty"`
+
+	Jobs []JobInfo `json:"jobs,omitempty"`
+
+	// Migrations is only set for tools using the database feature
+	Migrations *MigrationInfo `json:"migrations,omitempty"`
+}
+
+// JobInfo describes a job loaded from a tool
+type JobInfo struct {
+	Name     string `json:"name"`
+	Schedule string `json:"schedule"`
+	Timeout  string `json:"timeout"`
+
+	// Events are the names of the events which trigger the job
+	Events []string `json:"events,omitempty"`
+
+	// NextRun is the next time the job's schedule will fire, it is unset if the schedule is invalid
+	NextRun       *time.Time `json:"next_run,omitempty"`
+	ScheduleError string     `json:"schedule_error,omitempty"`
+}
+
+// MigrationInfo describes the state of a tool's database migrations
+type MigrationInfo struct {
+	Version uint   `json:"version"`
+	Dirty   bool   `json:"dirty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// Inspect returns information about each tool registered on the belt in registration order
+func (b *Belt) Inspect(ctx context.Context) []ToolInfo {
+	now := time.Now()
+
+	var infos []ToolInfo
+	for _, t := range b.tools {
+		info := ToolInfo{
+			Name:       t.Name(),
+			FeatureSet: t.FeatureSet(),
+		}
+
+		if httpTool, ok := t.(apis.HTTPTool); ok && info.FeatureSet.HTTP {
+			if info.FeatureSet.HTTPHost {
+				info.HTTPHost = httpTool.HTTPHost()
+			} else {
+				info.HTTPPath = httpTool.HTTPPath()
+			}
+		}
+
+		for _, job := range b.jobs[t.Name()] {
+			jobInfo := JobInfo{
+				Name:     job.Name(),
+				Schedule: job.Schedule(),
+				Timeout:  job.Timeout().String(),
+			}
+
+			if eventJob, ok := job.(apis.EventJob); ok {
+				jobInfo.Events = eventJob.Events()
+			}
+
+			if job.Schedule() != "" {
+				schedule, err := parseSchedule(job.Schedule())
+				if err != nil {
+					jobInfo.ScheduleError = err.Error()
+				} else {
+					next := schedule.Next(now)
+					jobInfo.NextRun = &next
+				}
+			}
+
+			info.Jobs = append(info.Jobs, jobInfo)
+		}
+
+		if info.FeatureSet.Database && b.db != nil {
+			info.Migrations = b.migrationInfo(ctx, t.Name())
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos
+}
+
+// migrationInfo looks up the applied migration version in the named tool's migrations table
+func (b *Belt) migrationInfo(ctx context.Context, name string) *MigrationInfo {
+	info := &MigrationInfo{}
+
+	row := b.db.QueryRowContext(
+		ctx,
+		fmt.Sprintf(`SELECT version, dirty FROM %s LIMIT 1`, pq.QuoteIdentifier(migrationsTable(name))),
+	)
+
+	var version int64
+	err := row.Scan(&version, &info.Dirty)
+	if err != nil {
+		info.Error = fmt.Sprintf("failed to get migration version: %s", err)
+		return info
+	}
+
+	info.Version = uint(version)
+
+	return info
+}
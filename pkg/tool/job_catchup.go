@@ -0,0 +1,155 @@
+package tool
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/doug-martin/goqu/v9"
+	"github.com/robfig/cron"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// catchUpJobs runs the occurrences of each job which were missed while the belt was not running, for jobs with a
+// catch up policy. It returns once all catch up runs are complete.
+func (b *Belt) catchUpJobs(ctx context.Context) {
+	if b.db == nil {
+		return
+	}
+
+	var wg sync.WaitGroup
+	for toolName, jobs := range b.jobs {
+		for _, job := range jobs {
+			catchUpJob, ok := job.(apis.CatchUpJob)
+			if !ok || catchUpJob.CatchUpPolicy().MaxRuns < 1 || job.Schedule() == "" {
+				continue
+			}
+
+			wg.Add(1)
+			go func(toolName string, job apis.CatchUpJob) {
+				defer wg.Done()
+				b.catchUpJob(ctx, toolName, job)
+			}(toolName, catchUpJob)
+		}
+	}
+	wg.Wait()
+}
+
+// catchUpJob runs the missed occurrences of a single job in order, oldest first
+func (b *Belt) catchUpJob(ctx context.Context, toolName string, job apis.CatchUpJob) {
+	jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+	occurrences, err := b.missedOccurrences(ctx, toolName, job, time.Now())
+	if err != nil {
+		log.Printf("failed to find missed runs of job %q: %v", jobRef, err)
+		return
+	}
+
+	for _, scheduledAt := range occurrences {
+		if ctx.Err() != nil {
+			return
+		}
+
+		if b.jobLockingEnabled() {
+			claimed, err := b.claimJobOccurrence(ctx, toolName, job.Name(), scheduledAt)
+			if err != nil {
+				log.Printf("failed to claim missed run of job %q, skipping: %v", jobRef, err)
+				continue
+			}
+			if !claimed {
+				continue
+			}
+		}
+
+		// catch up runs are subject to the job's concurrency policy like scheduled runs, since a run started by an
+		// event or RunJobNow may already be in progress
+		rj, _ := b.startJobRun(ctx, toolName, job)
+		if rj == nil {
+			continue
+		}
+
+		log.Printf("catching up missed run of job %q scheduled at %s", jobRef, scheduledAt.Format(time.RFC3339))
+		b.runTrackedJob(ctx, rj, toolName, job)
+	}
+}
+
+// missedOccurrences returns the occurrences of the job between its last successful run and now which are to be run
+// under its catch up policy. Jobs which have never succeeded have no missed occurrences.
+func (b *Belt) missedOccurrences(
+	ctx context.Context,
+	toolName string,
+	job apis.CatchUpJob,
+	now time.Time,
+) ([]time.Time, error) {
+	lastSuccess, err := b.lastSuccessfulJobRun(ctx, toolName, job.Name())
+	if err != nil {
+		return nil, err
+	}
+	if lastSuccess.IsZero() {
+		return nil, nil
+	}
+
+	schedule, err := parseSchedule(job.Schedule())
+	if err != nil {
+		return nil, err
+	}
+
+	return catchUpOccurrences(schedule, job.CatchUpPolicy(), lastSuccess, now), nil
+}
+
+// catchUpOccurrences returns the occurrences of the schedule after lastSuccess and up to now which are to be run under
+// the policy, oldest first
+func catchUpOccurrences(schedule cron.Schedule, policy apis.CatchUpPolicy, lastSuccess, now time.Time) []time.Time {
+	from := lastSuccess
+	if policy.MaxAge > 0 && from.Before(now.Add(-policy.MaxAge)) {
+		from = now.Add(-policy.MaxAge)
+	}
+
+	// only the most recent MaxRuns occurrences are kept, or the most recent one when the job is run once
+	var occurrences []time.Time
+	for next := schedule.Next(from); !next.IsZero() && !next.After(now); next = schedule.Next(next) {
+		occurrences = append(occurrences, next)
+		if len(occurrences) > policy.MaxRuns {
+			occurrences = occurrences[1:]
+		}
+	}
+
+	return occurrences
+}
+
+// lastSuccessfulJobRun returns the start time of the job's most recent successful run from the job run history, the
+// zero time is returned if the job has never succeeded
+func (b *Belt) lastSuccessfulJobRun(ctx context.Context, toolName, jobName string) (time.Time, error) {
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	goquDB := goqu.New("postgres", b.db)
+
+	sel := goquDB.From(jobRunsTable).
+		Select("started_at").
+		Where(goqu.Ex{
+			"tool_name": toolName,
+			"job_name":  jobName,
+			"outcome":   JobOutcomeSucceeded,
+		}).
+		Order(goqu.I("started_at").Desc()).
+		Limit(1)
+
+	var startedAt sql.NullTime
+	found, err := sel.ScanValContext(ctx, &startedAt)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return time.Time{}, fmt.Errorf("failed to select last successful job run: %w", err)
+	}
+	if !found || !startedAt.Valid {
+		return time.Time{}, nil
+	}
+
+	return startedAt.Time, nil
+}
@@ -0,0 +1,56 @@
+package tool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+func TestCatchUpOccurrences(t *testing.T) {
+	schedule, err := parseSchedule("0 0 * * * *")
+	require.NoError(t, err)
+
+	now := time.Date(2023, 1, 1, 12, 30, 0, 0, time.UTC)
+	hour := func(h int) time.Time { return time.Date(2023, 1, 1, h, 0, 0, 0, time.UTC) }
+
+	testCases := map[string]struct {
+		policy      apis.CatchUpPolicy
+		lastSuccess time.Time
+		expected    []time.Time
+	}{
+		"nothing missed": {
+			policy:      apis.CatchUpPolicy{MaxRuns: 3},
+			lastSuccess: hour(12),
+		},
+		"run once": {
+			policy:      apis.CatchUpPolicy{MaxRuns: 1},
+			lastSuccess: hour(8),
+			expected:    []time.Time{hour(12)},
+		},
+		"run each up to the limit": {
+			policy:      apis.CatchUpPolicy{MaxRuns: 3},
+			lastSuccess: hour(7),
+			expected:    []time.Time{hour(10), hour(11), hour(12)},
+		},
+		"run each under the limit": {
+			policy:      apis.CatchUpPolicy{MaxRuns: 10},
+			lastSuccess: hour(10),
+			expected:    []time.Time{hour(11), hour(12)},
+		},
+		"max age": {
+			policy:      apis.CatchUpPolicy{MaxRuns: 10, MaxAge: 2 * time.Hour},
+			lastSuccess: hour(1),
+			expected:    []time.Time{hour(11), hour(12)},
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			occurrences := catchUpOccurrences(schedule, testCase.policy, testCase.lastSuccess, now)
+			require.Equal(t, testCase.expected, occurrences)
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// runningJob is a run of a job which is in progress
+type runningJob struct {
+	toolName  string
+	jobName   string
+	startedAt time.Time
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+
+	// replaced is set when the run is cancelled to make way for a newer run
+	replaced atomic.Bool
+
+	// attempts counts the calls to the job's Run which have not returned, attempts abandoned when the run's context
+	// ended may still be running after the run has finished
+	attempts sync.WaitGroup
+}
+
+// returned returns a channel which is closed once the run has finished and all of its attempts have returned
+func (rj *runningJob) returned() <-chan struct{} {
+	ch := make(chan struct{})
+	go func() {
+		<-rj.done
+		rj.attempts.Wait()
+		close(ch)
+	}()
+
+	return ch
+}
+
+// newRunningJob returns a run of a job, the run's context is cancelled when the run is replaced
+func newRunningJob(ctx context.Context, toolName, jobName string) *runningJob {
+	runCtx, cancel := context.WithCancel(ctx)
+
+	return &runningJob{
+		toolName:  toolName,
+		jobName:   jobName,
+		startedAt: time.Now(),
+		ctx:       runCtx,
+		cancel:    cancel,
+		done:      make(chan struct{}),
+	}
+}
+
+// trackJob registers a run of a job as in progress without applying the job's concurrency policy
+func (b *Belt) trackJob(ctx context.Context, toolName, jobName string) *runningJob {
+	rj := newRunningJob(ctx, toolName, jobName)
+
+	b.runningMu.Lock()
+	defer b.runningMu.Unlock()
+
+	b.addRunningJob(rj)
+
+	return rj
+}
+
+// addRunningJob registers rj as in progress, b.runningMu must be held
+func (b *Belt) addRunningJob(rj *runningJob) {
+	if b.running == nil {
+		b.running = make(map[*runningJob]struct{})
+	}
+	b.running[rj] = struct{}{}
+}
+
+// untrackJob marks a run as finished. The run is removed from the runs in progress once any attempts abandoned by the
+// run have returned, so that they are still seen by concurrency policies.
+func (b *Belt) untrackJob(rj *runningJob) {
+	rj.cancel()
+	close(rj.done)
+
+	go func() {
+		rj.attempts.Wait()
+
+		b.runningMu.Lock()
+		defer b.runningMu.Unlock()
+
+		delete(b.running, rj)
+	}()
+}
+
+// runningJobs returns the runs of the named job which are in progress
+func (b *Belt) runningJobs(toolName, jobName string) []*runningJob {
+	b.runningMu.Lock()
+	defer b.runningMu.Unlock()
+
+	return b.runningJobsLocked(toolName, jobName)
+}
+
+// runningJobsLocked returns the runs of the named job which are in progress, b.runningMu must be held
+func (b *Belt) runningJobsLocked(toolName, jobName string) []*runningJob {
+	var runs []*runningJob
+	for rj := range b.running {
+		if rj.toolName == toolName && rj.jobName == jobName {
+			runs = append(runs, rj)
+		}
+	}
+
+	return runs
+}
+
+// allRunningJobs returns all runs of jobs which are in progress
+func (b *Belt) allRunningJobs() []*runningJob {
+	b.runningMu.Lock()
+	defer b.runningMu.Unlock()
+
+	runs := make([]*runningJob, 0, len(b.running))
+	for rj := range b.running {
+		runs = append(runs, rj)
+	}
+
+	return runs
+}
+
+// cancelRunningJobs cancels the contexts of all runs in progress and returns the runs
+func (b *Belt) cancelRunningJobs() []*runningJob {
+	runs := b.allRunningJobs()
+	for _, rj := range runs {
+		rj.cancel()
+	}
+
+	return runs
+}
+
+// jobConcurrencyPolicy returns the concurrency policy for the job, jobs without a policy allow concurrent runs
+func jobConcurrencyPolicy(job apis.Job) apis.ConcurrencyPolicy {
+	concurrencyPolicyJob, ok := job.(apis.ConcurrencyPolicyJob)
+	if !ok {
+		return apis.ConcurrencyAllow
+	}
+
+	return concurrencyPolicyJob.ConcurrencyPolicy()
+}
+
+// startJobRun applies the job's concurrency policy before a run starts and tracks the new run. It returns nil and
+// records the run as skipped, returning the skipped run, if the run should not start. Previous runs replaced by the
+// new run have returned by the time it starts.
+func (b *Belt) startJobRun(ctx context.Context, toolName string, job apis.Job) (*runningJob, JobRun) {
+	jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+	rj, replaced, skipReason, err := b.trackJobWithPolicy(ctx, toolName, job)
+	if err != nil {
+		log.Printf("failed to apply concurrency policy for job %q, skipping: %v", jobRef, err)
+		return nil, b.skipJob(toolName, job, fmt.Sprintf("failed to apply concurrency policy: %s", err))
+	}
+	if skipReason != "" {
+		log.Printf("skipping job %q, %s", jobRef, skipReason)
+		return nil, b.skipJob(toolName, job, skipReason)
+	}
+
+	if len(replaced) > 0 {
+		log.Printf("replacing %d previous run(s) of job %q", len(replaced), jobRef)
+	}
+
+	// wait for the previous runs to return, including attempts which ignore cancellation, so that the runs don't
+	// overlap
+	for _, previous := range replaced {
+		select {
+		case <-previous.returned():
+		case <-ctx.Done():
+			b.untrackJob(rj)
+
+			log.Printf("failed to apply concurrency policy for job %q, skipping: %v", jobRef, ctx.Err())
+			return nil, b.skipJob(toolName, job, fmt.Sprintf("failed to apply concurrency policy: %s", ctx.Err()))
+		}
+	}
+
+	return rj, JobRun{}
+}
+
+// trackJobWithPolicy checks the job's concurrency policy against the runs in progress and tracks the new run under
+// the same lock, so that runs starting at the same time see each other. It returns a reason when the run should be
+// skipped, and the previous runs which were cancelled to make way for the new run.
+func (b *Belt) trackJobWithPolicy(
+	ctx context.Context,
+	toolName string,
+	job apis.Job,
+) (*runningJob, []*runningJob, string, error) {
+	b.runningMu.Lock()
+	defer b.runningMu.Unlock()
+
+	running := b.runningJobsLocked(toolName, job.Name())
+
+	var replaced []*runningJob
+	switch policy := jobConcurrencyPolicy(job); policy {
+	case apis.ConcurrencyAllow, "":
+	case apis.ConcurrencyForbid:
+		if len(running) > 0 {
+			return nil, nil, "previous run still in progress", nil
+		}
+	case apis.ConcurrencyReplace:
+		for _, rj := range running {
+			rj.replaced.Store(true)
+			rj.cancel()
+		}
+		replaced = running
+	default:
+		return nil, nil, "", fmt.Errorf("unknown concurrency policy %q", policy)
+	}
+
+	rj := newRunningJob(ctx, toolName, job.Name())
+	b.addRunningJob(rj)
+
+	return rj, replaced, "", nil
+}
@@ -0,0 +1,183 @@
+package tool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// blockingJob runs until its context is done and reports the number of runs started
+type blockingJob struct {
+	policy  apis.ConcurrencyPolicy
+	started chan struct{}
+}
+
+func (j *blockingJob) Name() string { return "blocking-job" }
+
+func (j *blockingJob) Run(ctx context.Context) error {
+	j.started <- struct{}{}
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (j *blockingJob) Timeout() time.Duration { return 10 * time.Second }
+
+func (j *blockingJob) Schedule() string { return "0 0 0 * * *" }
+
+func (j *blockingJob) ConcurrencyPolicy() apis.ConcurrencyPolicy { return j.policy }
+
+func TestJobConcurrencyPolicy(t *testing.T) {
+	testCases := map[string]struct {
+		policy          apis.ConcurrencyPolicy
+		expectedRunning int
+		// expectedOutcome is the outcome of the first run, or of the second run when the first is still running
+		expectedOutcome JobOutcome
+	}{
+		"allow": {
+			policy:          apis.ConcurrencyAllow,
+			expectedRunning: 2,
+		},
+		"forbid": {
+			policy:          apis.ConcurrencyForbid,
+			expectedRunning: 1,
+			expectedOutcome: JobOutcomeSkipped,
+		},
+		"replace": {
+			policy:          apis.ConcurrencyReplace,
+			expectedRunning: 1,
+			expectedOutcome: JobOutcomeCancelled,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			b := NewBelt()
+			job := &blockingJob{policy: testCase.policy, started: make(chan struct{}, 2)}
+
+			go b.runScheduledJob(ctx, "test", job, time.Now())
+			<-job.started
+
+			go b.runScheduledJob(ctx, "test", job, time.Now())
+
+			if testCase.expectedRunning == 2 || testCase.policy == apis.ConcurrencyReplace {
+				select {
+				case <-job.started:
+				case <-time.After(time.Second):
+					t.Fatal("second run did not start")
+				}
+			}
+
+			if testCase.expectedOutcome != "" {
+				require.Eventually(t, func() bool {
+					return b.metrics.jobRuns.Value("test", "blocking-job", string(testCase.expectedOutcome)) == 1
+				}, time.Second, 10*time.Millisecond)
+			}
+
+			// replaced runs stop being tracked once they have returned
+			require.Eventually(t, func() bool {
+				return len(b.runningJobs("test", "blocking-job")) == testCase.expectedRunning
+			}, time.Second, 10*time.Millisecond)
+		})
+	}
+}
+
+// stubbornJob ignores cancellation and runs until it is released
+type stubbornJob struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (j *stubbornJob) Name() string { return "stubborn-job" }
+
+func (j *stubbornJob) Run(ctx context.Context) error {
+	j.started <- struct{}{}
+	<-j.release
+	return nil
+}
+
+func (j *stubbornJob) Timeout() time.Duration { return 10 * time.Second }
+
+func (j *stubbornJob) Schedule() string { return "0 0 0 * * *" }
+
+func (j *stubbornJob) ConcurrencyPolicy() apis.ConcurrencyPolicy { return apis.ConcurrencyReplace }
+
+func TestJobConcurrencyReplaceWaitsForAbandonedRuns(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBelt()
+	job := &stubbornJob{started: make(chan struct{}, 2), release: make(chan struct{})}
+
+	go b.runScheduledJob(ctx, "test", job, time.Now())
+	<-job.started
+
+	// the first run is abandoned when it is replaced, but the second must not start until it returns
+	go b.runScheduledJob(ctx, "test", job, time.Now())
+
+	require.Eventually(t, func() bool {
+		return b.metrics.jobRuns.Value("test", "stubborn-job", string(JobOutcomeCancelled)) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	select {
+	case <-job.started:
+		t.Fatal("second run started while the replaced run was still running")
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	close(job.release)
+
+	select {
+	case <-job.started:
+	case <-time.After(time.Second):
+		t.Fatal("second run did not start")
+	}
+}
+
+func TestJobConcurrencyForbidConcurrentStarts(t *testing.T) {
+	b := NewBelt()
+	job := &blockingJob{policy: apis.ConcurrencyForbid}
+
+	// runs starting at the same time must see each other
+	started := make(chan *runningJob, 10)
+	for i := 0; i < cap(started); i++ {
+		go func() {
+			rj, _ := b.startJobRun(context.Background(), "test", job)
+			started <- rj
+		}()
+	}
+
+	var runs int
+	for i := 0; i < cap(started); i++ {
+		if rj := <-started; rj != nil {
+			runs++
+		}
+	}
+
+	require.Equal(t, 1, runs)
+}
+
+func TestJobConcurrencyRunJobNow(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	b := NewBelt()
+	job := &blockingJob{policy: apis.ConcurrencyForbid, started: make(chan struct{}, 2)}
+	b.jobs = map[string][]apis.Job{"test": {job}}
+
+	go b.runScheduledJob(ctx, "test", job, time.Now())
+	<-job.started
+
+	// manual runs are subject to the job's concurrency policy too
+	run, err := b.RunJobNow(ctx, "test", "blocking-job")
+	require.NoError(t, err)
+
+	require.Equal(t, JobOutcomeSkipped, run.Outcome)
+	require.Equal(t, "previous run still in progress", run.Error)
+}
@@ -0,0 +1,99 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"os"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// jobClaimRetention is how long claims on job occurrences are kept before being removed
+const jobClaimRetention = 7 * 24 * time.Hour
+
+// instanceName identifies this process in job claims and job runs so that runs can be traced to a replica
+func instanceName() string {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "unknown"
+	}
+
+	return fmt.Sprintf("%s-%d", hostname, os.Getpid())
+}
+
+// jobLockingEnabled returns true when jobs.locking.enabled is set. When enabled, each scheduled occurrence of a job
+// runs on only one of the belts sharing the database.
+func (b *Belt) jobLockingEnabled() bool {
+	config := gabs.Wrap(b.getConfig())
+	enabled, ok := config.Path("jobs.locking.enabled").Data().(bool)
+
+	return ok && enabled
+}
+
+// jobLockKey returns the key of the Postgres advisory lock used when claiming occurrences of a job
+func jobLockKey(toolName, jobName string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(toolName + "/" + jobName))
+
+	return int64(h.Sum64())
+}
+
+// claimJobOccurrence attempts to claim the occurrence of a job scheduled at scheduledAt for this belt. It returns
+// false if the occurrence is being, or has been, claimed by another belt sharing the database.
+func (b *Belt) claimJobOccurrence(ctx context.Context, toolName, jobName string, scheduledAt time.Time) (bool, error) {
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := b.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin job claim transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// the advisory lock serialises belts claiming the same job, it is released when the transaction ends
+	var locked bool
+	err = tx.QueryRowContext(ctx, `SELECT pg_try_advisory_xact_lock($1)`, jobLockKey(toolName, jobName)).Scan(&locked)
+	if err != nil {
+		return false, fmt.Errorf("failed to take job lock: %w", err)
+	}
+	if !locked {
+		return false, nil
+	}
+
+	// the claim is recorded so that a belt which is late to take the lock does not run the occurrence again
+	result, err := tx.ExecContext(
+		ctx,
+		`INSERT INTO toolbelt.job_claims (tool_name, job_name, scheduled_at, instance)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT DO NOTHING`,
+		toolName, jobName, scheduledAt, b.instance,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim job occurrence: %w", err)
+	}
+
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to check job claim: %w", err)
+	}
+
+	_, err = tx.ExecContext(
+		ctx,
+		`DELETE FROM toolbelt.job_claims WHERE tool_name = $1 AND job_name = $2 AND scheduled_at < $3`,
+		toolName, jobName, scheduledAt.Add(-jobClaimRetention),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to remove old job claims: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return false, fmt.Errorf("failed to commit job claim: %w", err)
+	}
+
+	return claimed == 1, nil
+}
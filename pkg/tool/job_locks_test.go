@@ -0,0 +1,59 @@
+package tool
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledOccurrence(t *testing.T) {
+	occurrence := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	testCases := map[string]struct {
+		schedule string
+		now      time.Time
+		expected time.Time
+	}{
+		"fired on time": {
+			schedule: "0 0 9 * * *",
+			now:      occurrence.Add(400 * time.Millisecond),
+			expected: occurrence,
+		},
+		"fired in the next second": {
+			schedule: "0 0 9 * * *",
+			now:      occurrence.Add(1600 * time.Millisecond),
+			expected: occurrence,
+		},
+		"every second": {
+			schedule: "* * * * * *",
+			now:      occurrence.Add(2600 * time.Millisecond),
+			expected: occurrence.Add(2 * time.Second),
+		},
+		"every": {
+			schedule: "@every 1m",
+			now:      occurrence.Add(600 * time.Millisecond),
+			expected: occurrence,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			schedule, err := parseSchedule(testCase.schedule)
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.expected, scheduledOccurrence(schedule, testCase.now))
+		})
+	}
+
+	t.Run("replicas find the same occurrence", func(t *testing.T) {
+		schedule, err := parseSchedule("0 */5 * * * *")
+		require.NoError(t, err)
+
+		// one belt fires just before a rounding boundary and another just after it
+		require.Equal(t,
+			scheduledOccurrence(schedule, occurrence.Add(400*time.Millisecond)),
+			scheduledOccurrence(schedule, occurrence.Add(600*time.Millisecond)),
+		)
+	})
+}
@@ -0,0 +1,42 @@
+package tool
+
+import (
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// jobRetryPolicy returns the retry policy for the job, jobs which are not retryable are attempted once
+func jobRetryPolicy(job apis.Job) apis.RetryPolicy {
+	retryableJob, ok := job.(apis.RetryableJob)
+	if !ok {
+		return apis.RetryPolicy{MaxAttempts: 1}
+	}
+
+	policy := retryableJob.RetryPolicy()
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+
+	return policy
+}
+
+// retryDelay returns how long to wait after the numbered attempt before trying again
+func retryDelay(policy apis.RetryPolicy, attempt int) time.Duration {
+	delay := float64(policy.InitialInterval)
+	if policy.Backoff == apis.BackoffExponential {
+		delay *= math.Pow(2, float64(attempt-1))
+	}
+
+	if policy.MaxInterval > 0 && delay > float64(policy.MaxInterval) {
+		delay = float64(policy.MaxInterval)
+	}
+
+	if policy.Jitter > 0 {
+		delay += delay * policy.Jitter * (rand.Float64()*2 - 1)
+	}
+
+	return time.Duration(delay)
+}
@@ -0,0 +1,146 @@
+package tool_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+var errPermanent = errors.New("permanent")
+
+// flakyJob fails until it has been run succeedAfter times
+type flakyJob struct {
+	runs         int
+	succeedAfter int
+	err          error
+}
+
+func (f *flakyJob) Name() string { return "flaky-job" }
+
+func (f *flakyJob) Run(ctx context.Context) error {
+	f.runs++
+	if f.runs < f.succeedAfter {
+		return f.err
+	}
+	return nil
+}
+
+func (f *flakyJob) Timeout() time.Duration { return time.Second }
+
+func (f *flakyJob) Schedule() string { return "0 0 0 * * *" }
+
+func (f *flakyJob) RetryPolicy() apis.RetryPolicy {
+	return apis.RetryPolicy{
+		MaxAttempts:     3,
+		Backoff:         apis.BackoffExponential,
+		InitialInterval: 10 * time.Millisecond,
+		Retryable: func(err error) bool {
+			return !errors.Is(err, errPermanent)
+		},
+	}
+}
+
+type jobsTool struct {
+	jobs []apis.Job
+}
+
+func (j *jobsTool) Name() string { return "test-jobs" }
+
+func (j *jobsTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Jobs: true} }
+
+func (j *jobsTool) SetConfig(config map[string]any) error { return nil }
+
+func (j *jobsTool) Jobs() ([]apis.Job, error) { return j.jobs, nil }
+
+func TestJobRetries(t *testing.T) {
+	testCases := map[string]struct {
+		job              *flakyJob
+		expectedOutcome  tool.JobOutcome
+		expectedAttempts int
+	}{
+		"succeeds after retries": {
+			job:              &flakyJob{succeedAfter: 3, err: fmt.Errorf("flaky")},
+			expectedOutcome:  tool.JobOutcomeSucceeded,
+			expectedAttempts: 3,
+		},
+		"fails after max attempts": {
+			job:              &flakyJob{succeedAfter: 10, err: fmt.Errorf("flaky")},
+			expectedOutcome:  tool.JobOutcomeFailed,
+			expectedAttempts: 3,
+		},
+		"does not retry permanent errors": {
+			job:              &flakyJob{succeedAfter: 10, err: fmt.Errorf("bad request: %w", errPermanent)},
+			expectedOutcome:  tool.JobOutcomeFailed,
+			expectedAttempts: 1,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tb := tool.NewBelt()
+
+			err := tb.AddTool(context.Background(), &jobsTool{jobs: []apis.Job{testCase.job}})
+			require.NoError(t, err)
+
+			run, err := tb.RunJobNow(context.Background(), "test-jobs", "flaky-job")
+			require.NoError(t, err)
+
+			require.Equal(t, testCase.expectedOutcome, run.Outcome)
+			require.Equal(t, testCase.expectedAttempts, run.Attempts)
+			require.Equal(t, testCase.expectedAttempts, testCase.job.runs)
+		})
+	}
+}
+
+// slowAttemptJob ignores cancellation on its first attempt and records the most attempts running at once
+type slowAttemptJob struct {
+	runs       atomic.Int32
+	running    atomic.Int32
+	maxRunning atomic.Int32
+}
+
+func (s *slowAttemptJob) Name() string { return "slow-attempt-job" }
+
+func (s *slowAttemptJob) Run(ctx context.Context) error {
+	running := s.running.Add(1)
+	defer s.running.Add(-1)
+	if running > s.maxRunning.Load() {
+		s.maxRunning.Store(running)
+	}
+
+	if s.runs.Add(1) == 1 {
+		time.Sleep(200 * time.Millisecond)
+	}
+	return nil
+}
+
+func (s *slowAttemptJob) Timeout() time.Duration { return 50 * time.Millisecond }
+
+func (s *slowAttemptJob) Schedule() string { return "0 0 0 * * *" }
+
+func (s *slowAttemptJob) RetryPolicy() apis.RetryPolicy {
+	return apis.RetryPolicy{MaxAttempts: 2, Budget: 5 * time.Second}
+}
+
+func TestJobRetriesWaitForAbandonedAttempts(t *testing.T) {
+	tb := tool.NewBelt()
+
+	job := &slowAttemptJob{}
+	err := tb.AddTool(context.Background(), &jobsTool{jobs: []apis.Job{job}})
+	require.NoError(t, err)
+
+	run, err := tb.RunJobNow(context.Background(), "test-jobs", "slow-attempt-job")
+	require.NoError(t, err)
+
+	require.Equal(t, tool.JobOutcomeSucceeded, run.Outcome)
+	require.Equal(t, 2, run.Attempts)
+	require.EqualValues(t, 1, job.maxRunning.Load())
+}
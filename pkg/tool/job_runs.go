@@ -0,0 +1,138 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/doug-martin/goqu/v9"
+	_ "github.com/doug-martin/goqu/v9/dialect/postgres"
+)
+
+// JobOutcome describes how a run of a job ended
+type JobOutcome string
+
+const (
+	// JobOutcomeSucceeded is used when the job returned without error
+	JobOutcomeSucceeded JobOutcome = "succeeded"
+	// JobOutcomeFailed is used when the job returned an error
+	JobOutcomeFailed JobOutcome = "failed"
+	// JobOutcomePanicked is used when the job panicked
+	JobOutcomePanicked JobOutcome = "panicked"
+	// JobOutcomeTimedOut is used when the job did not complete within its timeout
+	JobOutcomeTimedOut JobOutcome = "timed_out"
+	// JobOutcomeCancelled is used when the belt's context ended during the run
+	JobOutcomeCancelled JobOutcome = "cancelled"
+	// JobOutcomeSkipped is used when a scheduled run did not start, the reason is set as the run's error
+	JobOutcomeSkipped JobOutcome = "skipped"
+)
+
+// JobRun is a record of a single run of a job
+type JobRun struct {
+	ID         int64      `db:"id" goqu:"skipinsert" json:"id"`
+	ToolName   string     `db:"tool_name" json:"tool_name"`
+	JobName    string     `db:"job_name" json:"job_name"`
+	StartedAt  time.Time  `db:"started_at" json:"started_at"`
+	FinishedAt time.Time  `db:"finished_at" json:"finished_at"`
+	Outcome    JobOutcome `db:"outcome" json:"outcome"`
+	Error      string     `db:"error" json:"error,omitempty"`
+	Panic      string     `db:"panic" json:"panic,omitempty"`
+
+	// Attempts is the number of times the job was run, this is more than one when a retryable job is retried
+	Attempts int `db:"attempts" json:"attempts"`
+
+	// Instance identifies the process which ran the job when several belts share a database
+	Instance string `db:"instance" json:"instance"`
+}
+
+var jobRunsTable = goqu.S("toolbelt").Table("job_runs")
+
+// recordJobRun stores the run in the belt's job run history and sets the run's ID
+func (b *Belt) recordJobRun(run *JobRun) error {
+	// runs are often recorded as the belt's context is ending, so a separate context is used to make sure the record
+	// is still written
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	goquDB := goqu.New("postgres", b.db)
+
+	ins := goquDB.Insert(jobRunsTable).
+		Rows(run).
+		Returning("id")
+
+	_, err = ins.Executor().ScanValContext(ctx, &run.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert job run: %w", err)
+	}
+
+	retention := b.jobRunRetention()
+	if retention <= 0 {
+		return nil
+	}
+
+	// the job's most recent successful run is kept regardless of its age, since it's used to find missed occurrences
+	// to catch up on
+	_, err = b.db.ExecContext(
+		ctx,
+		`DELETE FROM toolbelt.job_runs
+		WHERE tool_name = $1 AND job_name = $2 AND started_at < $3
+		AND id NOT IN (
+			SELECT id FROM toolbelt.job_runs
+			WHERE tool_name = $1 AND job_name = $2 AND outcome = $4
+			ORDER BY started_at DESC
+			LIMIT 1
+		)`,
+		run.ToolName, run.JobName, run.StartedAt.Add(-retention), JobOutcomeSucceeded,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to remove old job runs: %w", err)
+	}
+
+	return nil
+}
+
+// defaultJobRunRetention is how long job runs are kept in the job run history when jobs.history.retention is not set
+const defaultJobRunRetention = 30 * 24 * time.Hour
+
+// jobRunRetention returns how long runs are kept in the job run history, set with jobs.history.retention. Older runs
+// of a job are removed as its new runs are recorded, a retention of zero keeps every run.
+func (b *Belt) jobRunRetention() time.Duration {
+	config := gabs.Wrap(b.getConfig())
+
+	return configDuration(config.Path("jobs.history.retention"), defaultJobRunRetention)
+}
+
+// JobRuns returns the most recent runs of a job, newest first. At most limit runs are returned.
+func (b *Belt) JobRuns(ctx context.Context, toolName, jobName string, limit uint) ([]JobRun, error) {
+	if b.db == nil {
+		return nil, fmt.Errorf("job run history requires a database but none was provided")
+	}
+
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	goquDB := goqu.New("postgres", b.db)
+
+	sel := goquDB.From(jobRunsTable).
+		Where(goqu.Ex{
+			"tool_name": toolName,
+			"job_name":  jobName,
+		}).
+		Order(goqu.I("started_at").Desc()).
+		Limit(limit)
+
+	var runs []JobRun
+	if err := sel.ScanStructsContext(ctx, &runs); err != nil {
+		return nil, fmt.Errorf("failed to select job runs: %w", err)
+	}
+
+	return runs, nil
+}
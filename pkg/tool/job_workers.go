@@ -0,0 +1,165 @@
+package tool
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// jobWorkerLimits are the limits on concurrently running jobs, set with jobs.workers. A limit of zero means there is
+// no limit.
+//
+//	jobs:
+//	  workers:
+//	    limit: 2
+//	    tools:
+//	      example-tool:
+//	        limit: 1
+//	        priority: 10
+type jobWorkerLimits struct {
+	limit int
+	tools map[string]jobWorkerToolLimits
+}
+
+// jobWorkerToolLimits are the limits for the jobs of one tool, runs of jobs from tools with a higher priority are
+// started first when runs are waiting for a worker
+type jobWorkerToolLimits struct {
+	limit    int
+	priority int
+}
+
+// jobWorkerLimits reads the current worker limits from the belt config
+func (b *Belt) jobWorkerLimits() jobWorkerLimits {
+	config := gabs.Wrap(b.getConfig())
+
+	limits := jobWorkerLimits{
+		limit: configInt(config.Search("jobs", "workers", "limit")),
+		tools: make(map[string]jobWorkerToolLimits),
+	}
+
+	for toolName, toolConfig := range config.Search("jobs", "workers", "tools").ChildrenMap() {
+		limits.tools[toolName] = jobWorkerToolLimits{
+			limit:    configInt(toolConfig.Search("limit")),
+			priority: configInt(toolConfig.Search("priority")),
+		}
+	}
+
+	return limits
+}
+
+// jobWorkers limits the number of job runs in progress at once, runs over the limit wait in a queue ordered by their
+// tool's priority and then by the time they started waiting
+type jobWorkers struct {
+	limits func() jobWorkerLimits
+
+	mu          sync.Mutex
+	running     int
+	toolRunning map[string]int
+	waiting     []*jobWorkerWaiter
+	seq         uint64
+}
+
+type jobWorkerWaiter struct {
+	toolName string
+	priority int
+	seq      uint64
+	ready    chan struct{}
+}
+
+func newJobWorkers(limits func() jobWorkerLimits) *jobWorkers {
+	return &jobWorkers{
+		limits:      limits,
+		toolRunning: make(map[string]int),
+	}
+}
+
+// acquire waits for a worker to be available for a run of a job from the named tool, it returns an error if ctx is
+// done first. Each successful call must be followed by a call to release.
+func (w *jobWorkers) acquire(ctx context.Context, toolName string) error {
+	w.mu.Lock()
+
+	limits := w.limits()
+
+	w.seq++
+	waiter := &jobWorkerWaiter{
+		toolName: toolName,
+		priority: limits.tools[toolName].priority,
+		seq:      w.seq,
+		ready:    make(chan struct{}),
+	}
+	w.waiting = append(w.waiting, waiter)
+	sort.SliceStable(w.waiting, func(i, j int) bool {
+		if w.waiting[i].priority != w.waiting[j].priority {
+			return w.waiting[i].priority > w.waiting[j].priority
+		}
+		return w.waiting[i].seq < w.waiting[j].seq
+	})
+
+	w.dispatch(limits)
+	w.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		return nil
+	case <-ctx.Done():
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	select {
+	case <-waiter.ready:
+		// the worker was granted as ctx finished, it's handed on to the next waiting run instead
+		w.running--
+		w.toolRunning[toolName]--
+	default:
+		for i := range w.waiting {
+			if w.waiting[i] == waiter {
+				w.waiting = append(w.waiting[:i], w.waiting[i+1:]...)
+				break
+			}
+		}
+	}
+
+	w.dispatch(w.limits())
+
+	return ctx.Err()
+}
+
+// release returns the worker used by a run of a job from the named tool
+func (w *jobWorkers) release(toolName string) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.running--
+	w.toolRunning[toolName]--
+
+	w.dispatch(w.limits())
+}
+
+// dispatch starts waiting runs while there are workers available, runs are skipped over if their tool is at its own
+// limit. w.mu must be held.
+func (w *jobWorkers) dispatch(limits jobWorkerLimits) {
+	remaining := w.waiting[:0]
+
+	for _, waiter := range w.waiting {
+		if limits.limit > 0 && w.running >= limits.limit {
+			remaining = append(remaining, waiter)
+			continue
+		}
+
+		toolLimit := limits.tools[waiter.toolName].limit
+		if toolLimit > 0 && w.toolRunning[waiter.toolName] >= toolLimit {
+			remaining = append(remaining, waiter)
+			continue
+		}
+
+		w.running++
+		w.toolRunning[waiter.toolName]++
+		close(waiter.ready)
+	}
+
+	w.waiting = remaining
+}
@@ -0,0 +1,94 @@
+package tool
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestJobWorkers(t *testing.T) {
+	limits := jobWorkerLimits{
+		limit: 2,
+		tools: map[string]jobWorkerToolLimits{
+			"limited":   {limit: 1},
+			"important": {priority: 10},
+		},
+	}
+	workers := newJobWorkers(func() jobWorkerLimits { return limits })
+
+	ctx := context.Background()
+
+	require.NoError(t, workers.acquire(ctx, "limited"))
+
+	// the tool limit is reached, so this run waits even though the belt limit is not
+	timeoutCtx, cancel := context.WithTimeout(ctx, 20*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, workers.acquire(timeoutCtx, "limited"), context.DeadlineExceeded)
+
+	require.NoError(t, workers.acquire(ctx, "other"))
+
+	// the belt limit is reached, runs wait and are started in priority order as workers are released
+	started := make(chan string, 2)
+	for i, toolName := range []string{"other", "important"} {
+		i, toolName := i, toolName
+		go func() {
+			require.NoError(t, workers.acquire(ctx, toolName))
+			started <- toolName
+		}()
+		require.Eventually(t, func() bool {
+			workers.mu.Lock()
+			defer workers.mu.Unlock()
+			return len(workers.waiting) == i+1
+		}, time.Second, time.Millisecond)
+	}
+
+	workers.release("limited")
+	require.Equal(t, "important", <-started)
+
+	workers.release("other")
+	require.Equal(t, "other", <-started)
+
+	workers.mu.Lock()
+	defer workers.mu.Unlock()
+	require.Equal(t, 2, workers.running)
+	require.Empty(t, workers.waiting)
+}
+
+// ignoringJob times out quickly but ignores cancellation until it is released
+type ignoringJob struct {
+	release chan struct{}
+}
+
+func (j *ignoringJob) Name() string { return "ignoring-job" }
+
+func (j *ignoringJob) Run(ctx context.Context) error {
+	<-j.release
+	return nil
+}
+
+func (j *ignoringJob) Timeout() time.Duration { return 20 * time.Millisecond }
+
+func (j *ignoringJob) Schedule() string { return "0 0 0 * * *" }
+
+func TestJobWorkersHeldByAbandonedAttempts(t *testing.T) {
+	b := NewBelt()
+	b.jobWorkers = newJobWorkers(func() jobWorkerLimits { return jobWorkerLimits{limit: 1} })
+
+	job := &ignoringJob{release: make(chan struct{})}
+
+	run := b.runJob(context.Background(), "test", job)
+	require.Equal(t, JobOutcomeTimedOut, run.Outcome)
+
+	// the abandoned attempt is still running, so it keeps the only worker
+	timeoutCtx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, b.jobWorkers.acquire(timeoutCtx, "test"), context.DeadlineExceeded)
+
+	close(job.release)
+
+	acquireCtx, cancelAcquire := context.WithTimeout(context.Background(), time.Second)
+	defer cancelAcquire()
+	require.NoError(t, b.jobWorkers.acquire(acquireCtx, "test"))
+}
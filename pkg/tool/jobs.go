@@ -0,0 +1,569 @@
+package tool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/robfig/cron"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// AddJob adds a job to the belt to be run by RunJobs, and when events it subscribes to are published. An error is
+// returned if the job's schedule is invalid.
+func (b *Belt) AddJob(toolName string, job apis.Job) error {
+	eventJob, isEventJob := job.(apis.EventJob)
+
+	// jobs triggered by events don't need a schedule
+	if job.Schedule() != "" || !isEventJob {
+		_, err := parseSchedule(job.Schedule())
+		if err != nil {
+			return fmt.Errorf("failed to add job %s/%s: %w", toolName, job.Name(), err)
+		}
+	}
+
+	if isEventJob {
+		b.subscribeJob(toolName, eventJob)
+	}
+
+	if _, ok := b.jobs[toolName]; !ok {
+		b.jobs[toolName] = []apis.Job{}
+	}
+
+	b.jobs[toolName] = append(b.jobs[toolName], job)
+
+	return nil
+}
+
+// RunJobs runs the belt's jobs on their schedules until ctx is cancelled. Occurrences of jobs with a catch up policy
+// which were missed while the belt was not running are run first.
+//
+// When ctx is cancelled no further runs are started and the contexts of runs in progress are cancelled. RunJobs waits
+// up to jobs.drain.timeout, which defaults to shutdown.timeout, for the runs to return. An error listing the jobs
+// which are still running is returned if they do not return in time. The status of unfinished external jobs is polled
+// while RunJobs is running.
+func (b *Belt) RunJobs(ctx context.Context) error {
+	return b.runJobs(ctx, b.newShutdownDeadline())
+}
+
+// runJobs runs the belt's jobs as RunJobs does, the drain ends by the shutdown deadline if it is sooner than the drain
+// timeout
+func (b *Belt) runJobs(ctx context.Context, deadline *shutdownDeadline) error {
+	if b.db != nil {
+		err := b.beltDatabaseMigrate(ctx)
+		if err != nil {
+			log.Printf("failed to prepare belt database, job runs will not be recorded: %v", err)
+		}
+	}
+
+	b.catchUpJobs(ctx)
+
+	// unfinished external jobs are polled while jobs run, so that the time they finish is recorded
+	pollerDone := make(chan struct{})
+	go func() {
+		defer close(pollerDone)
+		b.pollExternalJobs(ctx)
+	}()
+	defer func() { <-pollerDone }()
+
+	// scheduled runs are cancelled once the scheduler has stopped rather than as soon as ctx is cancelled, so that a
+	// run starting during shutdown is tracked and cancelled like the others
+	jobsCtx, cancelJobs := context.WithCancel(context.Background())
+	defer cancelJobs()
+
+	var runs scheduledRuns
+
+	crn := cron.New()
+
+	for toolName, jobs := range b.jobs {
+		for i := range jobs {
+			toolName := toolName
+			job := b.jobs[toolName][i]
+
+			jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+			if job.Schedule() == "" {
+				continue
+			}
+
+			log.Printf("loaded job %q with schedule %q", jobRef, job.Schedule())
+
+			// schedules are validated when jobs are added
+			schedule, err := parseSchedule(job.Schedule())
+			if err != nil {
+				log.Printf("failed to add job %q to cron: %v", jobRef, err)
+				continue
+			}
+
+			crn.Schedule(schedule, cron.FuncJob(func() {
+				// the occurrence is found before anything which may block, so that every belt sharing the database
+				// claims the same occurrence
+				scheduledAt := scheduledOccurrence(schedule, time.Now())
+
+				if !runs.start() {
+					return
+				}
+				defer runs.done()
+
+				b.runScheduledJob(jobsCtx, toolName, job, scheduledAt)
+			}))
+		}
+	}
+
+	crn.Start()
+	b.setEventRuns(jobsCtx, &runs)
+	log.Printf("job worker started")
+
+	<-ctx.Done()
+
+	log.Println("stopping job worker")
+	crn.Stop()
+	runs.stop()
+	b.setEventRuns(nil, nil)
+
+	cancelJobs()
+
+	return b.drainJobs(&runs, b.jobsDrainTimeout(), deadline)
+}
+
+// scheduledRuns tracks the scheduled and event triggered runs started while RunJobs is running, including those which have not yet been tracked as
+// running jobs while their concurrency policy and lock are checked
+type scheduledRuns struct {
+	mu      sync.Mutex
+	stopped bool
+	wg      sync.WaitGroup
+}
+
+// start returns false if RunJobs is stopping and the run should not start
+func (s *scheduledRuns) start() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stopped {
+		return false
+	}
+	s.wg.Add(1)
+
+	return true
+}
+
+func (s *scheduledRuns) done() {
+	s.wg.Done()
+}
+
+// stop prevents further runs from starting
+func (s *scheduledRuns) stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stopped = true
+}
+
+// drainJobs cancels all job runs in progress and waits until timeout, or the shutdown deadline, for them and the
+// scheduled runs to return. Attempts which were abandoned when their run was cancelled are waited for too.
+func (b *Belt) drainJobs(runs *scheduledRuns, timeout time.Duration, deadline *shutdownDeadline) error {
+	drainCtx, cancel := deadline.context(timeout)
+	defer cancel()
+
+	// runs started by events or RunJobNow are cancelled too, since the belt is shutting down
+	running := b.cancelRunningJobs()
+
+	scheduledDone := make(chan struct{})
+	go func() {
+		runs.wg.Wait()
+		close(scheduledDone)
+	}()
+
+	select {
+	case <-scheduledDone:
+	case <-drainCtx.Done():
+	}
+
+	// runs may have started while the scheduled runs were stopping
+	seen := make(map[*runningJob]struct{}, len(running))
+	for _, rj := range running {
+		seen[rj] = struct{}{}
+	}
+	for _, rj := range b.cancelRunningJobs() {
+		if _, ok := seen[rj]; !ok {
+			running = append(running, rj)
+		}
+	}
+
+	var stillRunning []*runningJob
+	for _, rj := range running {
+		select {
+		case <-rj.returned():
+		case <-drainCtx.Done():
+			stillRunning = append(stillRunning, rj)
+		}
+	}
+
+	if len(stillRunning) > 0 {
+		return jobsStillRunningError(stillRunning, timeout)
+	}
+
+	log.Println("job worker stopped")
+
+	return nil
+}
+
+// jobsStillRunningError logs and returns an error listing the job runs which had not returned after the drain timeout
+func jobsStillRunningError(running []*runningJob, timeout time.Duration) error {
+	refs := make([]string, 0, len(running))
+	for _, rj := range running {
+		refs = append(refs, fmt.Sprintf(
+			"%s/%s (running for %s)",
+			rj.toolName, rj.jobName, time.Since(rj.startedAt).Round(time.Millisecond),
+		))
+	}
+	sort.Strings(refs)
+
+	log.Printf("job worker stopped with jobs still running after %s: %s", timeout, strings.Join(refs, ", "))
+
+	return fmt.Errorf("jobs still running after drain timeout of %s: %s", timeout, strings.Join(refs, ", "))
+}
+
+// jobsDrainTimeout returns the time RunJobs waits for job runs to return when stopping, set with jobs.drain.timeout
+func (b *Belt) jobsDrainTimeout() time.Duration {
+	config := gabs.Wrap(b.getConfig())
+
+	return configDuration(config.Path("jobs.drain.timeout"), b.shutdownTimeout())
+}
+
+// ErrJobNotFound is returned when running a job which is not registered with the belt
+var ErrJobNotFound = errors.New("job not found")
+
+// findJob returns the named job, or an error wrapping ErrJobNotFound
+func (b *Belt) findJob(toolName, jobName string) (apis.Job, error) {
+	for _, job := range b.jobs[toolName] {
+		if job.Name() == jobName {
+			return job, nil
+		}
+	}
+
+	return nil, fmt.Errorf("failed to find job %s/%s: %w", toolName, jobName, ErrJobNotFound)
+}
+
+// RunJobNow runs the named job immediately, outside of its schedule, and returns the outcome of the run. The job is
+// run with the same concurrency policy, worker limit, timeout, panic recovery and logging as scheduled runs, the
+// skipped run is returned when the job's concurrency policy doesn't allow it to start.
+func (b *Belt) RunJobNow(ctx context.Context, toolName, jobName string) (JobRun, error) {
+	job, err := b.findJob(toolName, jobName)
+	if err != nil {
+		return JobRun{}, err
+	}
+
+	rj, skipped := b.startJobRun(ctx, toolName, job)
+	if rj == nil {
+		return skipped, nil
+	}
+
+	return b.runTrackedJob(ctx, rj, toolName, job), nil
+}
+
+// StartJobNow starts a run of the named job in the background, as RunJobNow does, and returns without waiting for it.
+// The run is not tied to the caller's context, it is stopped along with the belt's other job runs when RunJobs stops.
+func (b *Belt) StartJobNow(toolName, jobName string) error {
+	job, err := b.findJob(toolName, jobName)
+	if err != nil {
+		return err
+	}
+
+	go func() {
+		rj, _ := b.startJobRun(context.Background(), toolName, job)
+		if rj == nil {
+			return
+		}
+
+		b.runTrackedJob(context.Background(), rj, toolName, job)
+	}()
+
+	return nil
+}
+
+// runScheduledJob runs the occurrence of a job scheduled at scheduledAt. When job locking is enabled the run is
+// skipped unless this belt claims the occurrence. The claim is made before the job's concurrency policy is applied, so
+// that a belt which loses the claim leaves its previous runs alone.
+func (b *Belt) runScheduledJob(ctx context.Context, toolName string, job apis.Job, scheduledAt time.Time) {
+	jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+	if b.db != nil && b.jobLockingEnabled() {
+		claimed, err := b.claimJobOccurrence(ctx, toolName, job.Name(), scheduledAt)
+		if err != nil {
+			log.Printf("failed to claim job %q, skipping: %v", jobRef, err)
+			b.skipJob(toolName, job, fmt.Sprintf("failed to claim occurrence: %s", err))
+			return
+		}
+		if !claimed {
+			log.Printf("skipping job %q, occurrence at %s claimed by another belt", jobRef, scheduledAt.Format(time.RFC3339))
+			b.skipJob(toolName, job, "occurrence claimed by another belt")
+			return
+		}
+	}
+
+	rj, _ := b.startJobRun(ctx, toolName, job)
+	if rj == nil {
+		return
+	}
+
+	b.runTrackedJob(ctx, rj, toolName, job)
+}
+
+// skipJob records that a run of a job did not start and returns the skipped run
+func (b *Belt) skipJob(toolName string, job apis.Job, reason string) JobRun {
+	now := time.Now()
+
+	run := JobRun{
+		ToolName:   toolName,
+		JobName:    job.Name(),
+		StartedAt:  now,
+		FinishedAt: now,
+		Outcome:    JobOutcomeSkipped,
+		Error:      reason,
+		Instance:   b.instance,
+	}
+
+	b.metrics.observeJobRun(run)
+
+	if b.db != nil {
+		err := b.recordJobRun(&run)
+		if err != nil {
+			log.Printf("failed to record skipped run of job %q: %v", fmt.Sprintf("%s/%s", toolName, job.Name()), err)
+		}
+	}
+
+	return run
+}
+
+// runJob runs a single job without applying its concurrency policy, see runTrackedJob
+func (b *Belt) runJob(ctx context.Context, toolName string, job apis.Job) JobRun {
+	return b.runTrackedJob(ctx, b.trackJob(ctx, toolName, job.Name()), toolName, job)
+}
+
+// runTrackedJob runs a single job with its timeout, retry policy and panic recovery applied, logs the outcome and
+// records it in the job run history when the belt has a database. rj is the run tracked for the job, ctx is its parent
+// context.
+func (b *Belt) runTrackedJob(ctx context.Context, rj *runningJob, toolName string, job apis.Job) JobRun {
+	jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+	run := JobRun{
+		ToolName:  toolName,
+		JobName:   job.Name(),
+		StartedAt: time.Now(),
+		Instance:  b.instance,
+	}
+
+	// the run is tracked so that it can be replaced by a later run, parentCtx is kept to tell replacement apart from
+	// the caller cancelling the run
+	parentCtx := ctx
+	defer b.untrackJob(rj)
+	ctx = rj.ctx
+
+	policy := jobRetryPolicy(job)
+
+	// without a budget, all attempts share the job's timeout
+	budget := job.Timeout()
+	if policy.Budget > 0 {
+		budget = policy.Budget
+	}
+
+	log.Printf("running job %q", jobRef)
+	runCtx, cancel := context.WithTimeout(ctx, budget)
+	defer cancel()
+
+	// runs wait for a worker within their timeout when the belt is at its limit of running jobs
+	var result attemptResult
+	err := b.jobWorkers.acquire(runCtx, toolName)
+	if err != nil {
+		result = attemptResult{
+			err:       fmt.Errorf("failed waiting for a job worker: %w", err),
+			abandoned: true,
+			timedOut:  err == context.DeadlineExceeded,
+		}
+	} else {
+		result = b.runAttempts(ctx, runCtx, toolName, job, policy, &run, &rj.attempts)
+
+		// an abandoned attempt keeps its worker until it returns, so that the limit holds for jobs which ignore
+		// cancellation
+		if result.abandoned {
+			go func() {
+				rj.attempts.Wait()
+				b.jobWorkers.release(toolName)
+			}()
+		} else {
+			b.jobWorkers.release(toolName)
+		}
+	}
+
+	switch {
+	case result.panicked:
+		run.Outcome = JobOutcomePanicked
+		run.Panic = b.redactor.Redact(fmt.Sprint(result.panicValue))
+		log.Printf("error running job %q, panicked: %s", jobRef, run.Panic)
+	case result.err == nil:
+		run.Outcome = JobOutcomeSucceeded
+		log.Printf("ran job %q", jobRef)
+	case rj.replaced.Load() && parentCtx.Err() == nil:
+		run.Outcome = JobOutcomeCancelled
+		run.Error = "replaced by a newer run"
+		log.Printf("cancelled job %q, replaced by a newer run", jobRef)
+	case result.abandoned && ctx.Err() == context.DeadlineExceeded:
+		run.Outcome = JobOutcomeCancelled
+		run.Error = ctx.Err().Error()
+		log.Printf("parent context timed out during job %q", jobRef)
+	case ctx.Err() == context.Canceled:
+		// the run was cancelled by its caller, or because the belt is stopping, and returned or was abandoned
+		run.Outcome = JobOutcomeCancelled
+		run.Error = ctx.Err().Error()
+		log.Printf("parent context cancelled during job %q", jobRef)
+	case result.timedOut && ctx.Err() == nil:
+		// the job returned, or was abandoned, because its own context expired, this is a timeout rather than a failure
+		run.Outcome = JobOutcomeTimedOut
+		run.Error = b.redactor.Redact(result.err.Error())
+		log.Printf("job %q timed out after %s: %s", jobRef, job.Timeout(), run.Error)
+	default:
+		run.Outcome = JobOutcomeFailed
+		run.Error = b.redactor.Redact(result.err.Error())
+		log.Printf("error running job %q: %s", jobRef, run.Error)
+	}
+
+	run.FinishedAt = time.Now()
+
+	b.metrics.observeJobRun(run)
+
+	if b.db != nil {
+		err := b.recordJobRun(&run)
+		if err != nil {
+			log.Printf("failed to record run of job %q: %v", jobRef, err)
+		}
+	}
+
+	return run
+}
+
+// runAttempts runs the job until an attempt succeeds or the retry policy stops further attempts, the result of the
+// last attempt is returned. Retries stop when runCtx, which bounds all attempts, is done.
+func (b *Belt) runAttempts(
+	ctx, runCtx context.Context,
+	toolName string,
+	job apis.Job,
+	policy apis.RetryPolicy,
+	run *JobRun,
+	attempts *sync.WaitGroup,
+) attemptResult {
+	jobRef := fmt.Sprintf("%s/%s", toolName, job.Name())
+
+	var result attemptResult
+	for {
+		run.Attempts++
+
+		attemptCtx, cancelAttempt := runCtx, context.CancelFunc(func() {})
+		if policy.Budget > 0 {
+			attemptCtx, cancelAttempt = context.WithTimeout(runCtx, job.Timeout())
+		}
+
+		result = runAttempt(attemptCtx, job, attempts)
+		cancelAttempt()
+
+		b.metrics.jobAttempts.Inc(toolName, job.Name())
+
+		if result.err == nil || result.panicked || run.Attempts >= policy.MaxAttempts || ctx.Err() != nil {
+			break
+		}
+		if policy.Retryable != nil && !policy.Retryable(result.err) {
+			break
+		}
+
+		// an abandoned attempt may still be running, the next attempt waits for it so that attempts don't overlap
+		if result.abandoned {
+			select {
+			case <-result.returned:
+			case <-runCtx.Done():
+			}
+			if runCtx.Err() != nil {
+				log.Printf("job %q ran out of time to retry, waiting for abandoned attempt", jobRef)
+				break
+			}
+		}
+
+		delay := retryDelay(policy, run.Attempts)
+		log.Printf(
+			"attempt %d of job %q failed, retrying in %s: %s",
+			run.Attempts, jobRef, delay, b.redactor.Redact(result.err.Error()),
+		)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-runCtx.Done():
+			timer.Stop()
+		case <-timer.C:
+		}
+		if runCtx.Err() != nil {
+			log.Printf("job %q ran out of time to retry", jobRef)
+			break
+		}
+	}
+
+	return result
+}
+
+// attemptResult is the result of a single attempt to run a job
+type attemptResult struct {
+	err error
+
+	panicked   bool
+	panicValue any
+
+	// abandoned is set when the attempt's context ended before the job returned, returned is closed when the job
+	// returns
+	abandoned bool
+	returned  <-chan struct{}
+	// timedOut is set when the attempt's context deadline was reached
+	timedOut bool
+}
+
+// runAttempt runs the job once, returning when the job returns or panics, or when ctx is done. attempts is done when
+// the job returns, which may be after an abandoned attempt.
+func runAttempt(ctx context.Context, job apis.Job, attempts *sync.WaitGroup) attemptResult {
+	doneCh := make(chan error, 1)
+	panicCh := make(chan interface{}, 1)
+	returned := make(chan struct{})
+
+	attempts.Add(1)
+	go func() {
+		defer attempts.Done()
+		defer close(returned)
+		defer func() {
+			if p := recover(); p != nil {
+				panicCh <- p
+			}
+		}()
+
+		doneCh <- job.Run(ctx)
+	}()
+
+	select {
+	case err := <-doneCh:
+		return attemptResult{
+			err:      err,
+			timedOut: err != nil && ctx.Err() == context.DeadlineExceeded,
+		}
+	case p := <-panicCh:
+		return attemptResult{panicked: true, panicValue: p}
+	case <-ctx.Done():
+		return attemptResult{
+			err:       ctx.Err(),
+			abandoned: true,
+			returned:  returned,
+			timedOut:  ctx.Err() == context.DeadlineExceeded,
+		}
+	}
+}
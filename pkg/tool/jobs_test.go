@@ -0,0 +1,102 @@
+package tool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+// drainJob runs every second and signals when a run starts, it returns when its context is done unless it ignores
+// cancellation
+type drainJob struct {
+	started      chan struct{}
+	ignoreCancel bool
+}
+
+func (d *drainJob) Name() string { return "drain-job" }
+
+func (d *drainJob) Run(ctx context.Context) error {
+	select {
+	case d.started <- struct{}{}:
+	default:
+	}
+
+	if d.ignoreCancel {
+		time.Sleep(3 * time.Second)
+		return nil
+	}
+
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func (d *drainJob) Timeout() time.Duration { return time.Minute }
+
+func (d *drainJob) Schedule() string { return "* * * * * *" }
+
+func TestRunJobs(t *testing.T) {
+	testCases := map[string]struct {
+		ignoreCancel  bool
+		expectedError string
+	}{
+		"running jobs are cancelled": {},
+		"jobs still running after the drain timeout are reported": {
+			ignoreCancel:  true,
+			expectedError: "jobs still running after drain timeout of 100ms: test-jobs/drain-job (running for",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tb := tool.NewBelt()
+			tb.SetConfig(map[string]any{
+				"jobs": map[string]any{
+					"drain": map[string]any{"timeout": "100ms"},
+				},
+			})
+
+			job := &drainJob{started: make(chan struct{}, 1), ignoreCancel: testCase.ignoreCancel}
+			err := tb.AddTool(context.Background(), &jobsTool{jobs: []apis.Job{job}})
+			require.NoError(t, err)
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			errs := make(chan error, 1)
+			go func() {
+				errs <- tb.RunJobs(ctx)
+			}()
+
+			select {
+			case <-job.started:
+			case <-time.After(3 * time.Second):
+				t.Fatal("job did not run")
+			}
+
+			// RunJobs blocks while the belt is running
+			select {
+			case err := <-errs:
+				t.Fatalf("RunJobs returned before it was stopped: %v", err)
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			cancel()
+
+			select {
+			case err := <-errs:
+				if testCase.expectedError == "" {
+					require.NoError(t, err)
+				} else {
+					require.ErrorContains(t, err, testCase.expectedError)
+				}
+			case <-time.After(time.Second):
+				t.Fatal("RunJobs did not return after it was stopped")
+			}
+		})
+	}
+}
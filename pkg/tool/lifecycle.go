@@ -0,0 +1,173 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// StartTools calls LifecycleStart on each tool using the lifecycle feature, and TCPStart on each tool using the TCP
+// feature, in the order the tools were added. TCP tools are expected to close their listeners when the context they
+// were started with is cancelled, which happens when they are stopped by StopTools or ctx is cancelled. If a tool fails
+// to start, the tools started so far are stopped again and the error is returned.
+//
+// TCP tools were started by AddTool in earlier versions of the belt, they are now only started by StartTools and Run.
+func (b *Belt) StartTools(ctx context.Context) error {
+	for _, t := range b.tools {
+		err := b.startTool(ctx, t)
+		if err != nil {
+			stopCtx, cancel := context.WithTimeout(context.Background(), b.shutdownTimeout())
+			defer cancel()
+
+			if stopErr := b.StopTools(stopCtx); stopErr != nil {
+				return fmt.Errorf("%s, and then %s", err, stopErr)
+			}
+
+			return err
+		}
+	}
+
+	return nil
+}
+
+// startedTool is a tool feature which has been started by StartTools, stop is called by StopTools to stop it again
+type startedTool struct {
+	name string
+	stop func(ctx context.Context) error
+}
+
+// startTool starts a single tool's lifecycle and TCP features
+func (b *Belt) startTool(ctx context.Context, t apis.Tool) error {
+	lifecycleTool, ok := t.(apis.LifecycleTool)
+	if t.FeatureSet().Lifecycle && ok {
+		log.Printf("starting tool %q", t.Name())
+
+		err := lifecycleTool.LifecycleStart(ctx)
+		if err != nil {
+			return fmt.Errorf("failed to start tool %s: %w", t.Name(), err)
+		}
+
+		b.startedTools = append(b.startedTools, startedTool{name: t.Name(), stop: lifecycleTool.LifecycleStop})
+	}
+
+	tcpTool, ok := t.(apis.TCPTool)
+	if t.FeatureSet().TCP && ok {
+		log.Printf("starting TCP service for tool %q", t.Name())
+
+		// each TCP service has its own context so that it can be stopped along with the other tools
+		tcpCtx, cancel := context.WithCancel(ctx)
+
+		err := tcpTool.TCPStart(tcpCtx)
+		if err != nil {
+			cancel()
+			return fmt.Errorf("failed to start TCP service for tool %s: %w", t.Name(), err)
+		}
+
+		b.startedTools = append(b.startedTools, startedTool{
+			name: t.Name(),
+			stop: func(context.Context) error {
+				cancel()
+				return nil
+			},
+		})
+	}
+
+	return nil
+}
+
+// StopTools calls LifecycleStop on each started tool, and cancels the context of each started TCP service, in the
+// reverse order to which they were started. All tools share the deadline of ctx, every tool is stopped even if others
+// fail and the errors are returned together.
+func (b *Belt) StopTools(ctx context.Context) error {
+	var errs []string
+
+	for i := len(b.startedTools) - 1; i >= 0; i-- {
+		t := b.startedTools[i]
+
+		log.Printf("stopping tool %q", t.name)
+
+		err := t.stop(ctx)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to stop tool %s: %s", t.name, err))
+		}
+	}
+
+	b.startedTools = nil
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// stopToolsAfter stops the started tools by the shutdown deadline once the belt has stopped, err is the error the belt
+// stopped with and is returned along with any error stopping the tools
+func (b *Belt) stopToolsAfter(err error, deadline *shutdownDeadline) error {
+	// the belt's context has usually been cancelled at this point so a new one is used
+	ctx, cancel := deadline.context(b.shutdownTimeout())
+	defer cancel()
+
+	stopErr := b.StopTools(ctx)
+	switch {
+	case stopErr == nil:
+		return err
+	case err == nil:
+		return stopErr
+	default:
+		return fmt.Errorf("%w, and then %s", err, stopErr)
+	}
+}
+
+// shutdownDeadline is the deadline shared by the phases of the belt's shutdown, so that together they take no longer
+// than the shutdown timeout. The deadline is set when the first phase starts.
+type shutdownDeadline struct {
+	timeout time.Duration
+
+	once     sync.Once
+	deadline time.Time
+}
+
+// newShutdownDeadline returns a deadline which is timeout after the shutdown starts
+func (b *Belt) newShutdownDeadline() *shutdownDeadline {
+	return &shutdownDeadline{timeout: b.shutdownTimeout()}
+}
+
+// context returns a context for a phase of the shutdown which ends after timeout, or at the shutdown deadline if that
+// is sooner. The context is not derived from the belt's context, which has usually been cancelled by this point.
+func (d *shutdownDeadline) context(timeout time.Duration) (context.Context, context.CancelFunc) {
+	d.once.Do(func() {
+		d.deadline = time.Now().Add(d.timeout)
+	})
+
+	deadline := time.Now().Add(timeout)
+	if d.deadline.Before(deadline) {
+		deadline = d.deadline
+	}
+
+	return context.WithDeadline(context.Background(), deadline)
+}
+
+// shutdownTimeout returns the total time allowed for the belt to shut down, set with shutdown.timeout. The server
+// shutdown, job drain and stopping the tools all share this time.
+func (b *Belt) shutdownTimeout() time.Duration {
+	shutdownTimeout := 5 * time.Second
+
+	config := gabs.Wrap(b.getConfig())
+	shutdownTimeoutString, ok := config.Path("shutdown.timeout").Data().(string)
+	if ok {
+		duration, err := time.ParseDuration(shutdownTimeoutString)
+		if err == nil {
+			shutdownTimeout = duration
+		}
+	}
+
+	return shutdownTimeout
+}
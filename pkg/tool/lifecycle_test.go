@@ -0,0 +1,89 @@
+package tool_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+type lifecycleTool struct {
+	name     string
+	events   *[]string
+	startErr error
+}
+
+func (l *lifecycleTool) Name() string { return l.name }
+
+func (l *lifecycleTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Lifecycle: true} }
+
+func (l *lifecycleTool) SetConfig(config map[string]any) error { return nil }
+
+func (l *lifecycleTool) LifecycleStart(ctx context.Context) error {
+	*l.events = append(*l.events, "start "+l.name)
+	return l.startErr
+}
+
+func (l *lifecycleTool) LifecycleStop(ctx context.Context) error {
+	*l.events = append(*l.events, "stop "+l.name)
+	return nil
+}
+
+func TestStartStopTools(t *testing.T) {
+	tb := tool.NewBelt()
+
+	var events []string
+	for _, name := range []string{"a", "b", "c"} {
+		err := tb.AddTool(context.Background(), &lifecycleTool{name: name, events: &events})
+		require.NoError(t, err)
+	}
+
+	err := tb.StartTools(context.Background())
+	require.NoError(t, err)
+
+	err = tb.StopTools(context.Background())
+	require.NoError(t, err)
+
+	require.Equal(t, []string{"start a", "start b", "start c", "stop c", "stop b", "stop a"}, events)
+}
+
+func TestStartToolsFailure(t *testing.T) {
+	tb := tool.NewBelt()
+
+	var events []string
+	err := tb.AddTool(context.Background(), &lifecycleTool{name: "a", events: &events})
+	require.NoError(t, err)
+	err = tb.AddTool(context.Background(), &lifecycleTool{name: "b", events: &events, startErr: fmt.Errorf("boom")})
+	require.NoError(t, err)
+	err = tb.AddTool(context.Background(), &lifecycleTool{name: "c", events: &events})
+	require.NoError(t, err)
+
+	err = tb.StartTools(context.Background())
+	require.ErrorContains(t, err, "failed to start tool b: boom")
+
+	// only the tools which started are stopped
+	require.Equal(t, []string{"start a", "start b", "stop a"}, events)
+}
+
+func TestStartToolsFailureStopsTCPTools(t *testing.T) {
+	tb := tool.NewBelt()
+
+	var events []string
+	tcp := &tcpTool{started: make(chan struct{})}
+	err := tb.AddTools(
+		context.Background(),
+		tcp,
+		&lifecycleTool{name: "a", events: &events, startErr: fmt.Errorf("boom")},
+	)
+	require.NoError(t, err)
+
+	err = tb.StartTools(context.Background())
+	require.ErrorContains(t, err, "failed to start tool a: boom")
+
+	// TCP tools started before the failure are stopped too
+	require.Error(t, tcp.context().Err())
+}
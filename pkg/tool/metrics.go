@@ -0,0 +1,174 @@
+package tool
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/gorilla/mux"
+
+	"github.com/charlieegan3/toolbelt/pkg/metrics"
+)
+
+// beltMetricsToolLabel is the tool label used for requests to routes owned by the belt itself
+const beltMetricsToolLabel = "toolbelt"
+
+// beltMetrics holds the metrics the belt collects about HTTP requests, job runs, events and queued tasks
+type beltMetrics struct {
+	registry *metrics.Registry
+
+	httpRequests        *metrics.CounterVec
+	httpRequestDuration *metrics.HistogramVec
+
+	jobRuns        *metrics.CounterVec
+	jobRunDuration *metrics.HistogramVec
+	jobAttempts    *metrics.CounterVec
+
+	eventsPublished *metrics.CounterVec
+
+	tasksProcessed *metrics.CounterVec
+}
+
+func newBeltMetrics() *beltMetrics {
+	registry := metrics.NewRegistry()
+
+	return &beltMetrics{
+		registry: registry,
+		httpRequests: registry.NewCounterVec(
+			"toolbelt_http_requests_total",
+			"Total number of HTTP requests by tool, method and status code.",
+			"tool", "method", "status",
+		),
+		httpRequestDuration: registry.NewHistogramVec(
+			"toolbelt_http_request_duration_seconds",
+			"Latency of HTTP requests by tool and method.",
+			metrics.DefaultBuckets,
+			"tool", "method",
+		),
+		jobRuns: registry.NewCounterVec(
+			"toolbelt_job_runs_total",
+			"Total number of job runs by tool, job and outcome.",
+			"tool", "job", "outcome",
+		),
+		jobRunDuration: registry.NewHistogramVec(
+			"toolbelt_job_run_duration_seconds",
+			"Duration of job runs by tool and job.",
+			[]float64{.1, .5, 1, 5, 10, 30, 60, 300, 600, 1800, 3600},
+			"tool", "job",
+		),
+		jobAttempts: registry.NewCounterVec(
+			"toolbelt_job_attempts_total",
+			"Total number of attempts to run jobs by tool and job, including retries.",
+			"tool", "job",
+		),
+		eventsPublished: registry.NewCounterVec(
+			"toolbelt_events_published_total",
+			"Total number of events published by source and event name.",
+			"source", "event",
+		),
+		tasksProcessed: registry.NewCounterVec(
+			"toolbelt_tasks_processed_total",
+			"Total number of attempts to process queued tasks by tool, kind and outcome.",
+			"tool", "kind", "outcome",
+		),
+	}
+}
+
+// Metrics returns the registry holding the belt's metrics, tools may register their own metrics here too
+func (b *Belt) Metrics() *metrics.Registry {
+	return b.metrics.registry
+}
+
+// observeJobRun records the outcome and duration of a job run
+func (m *beltMetrics) observeJobRun(run JobRun) {
+	m.jobRuns.Inc(run.ToolName, run.JobName, string(run.Outcome))
+	m.jobRunDuration.Observe(run.FinishedAt.Sub(run.StartedAt).Seconds(), run.ToolName, run.JobName)
+}
+
+// matchMetricsPath matches requests for the metrics path, which is read from the config on each request so that it
+// can be set after the route is registered
+func (b *Belt) matchMetricsPath(r *http.Request, _ *mux.RouteMatch) bool {
+	return r.URL.Path == b.metricsPath()
+}
+
+// metricsPath returns the path the metrics are served on, set with metrics.path
+func (b *Belt) metricsPath() string {
+	config := gabs.Wrap(b.getConfig())
+	path, ok := config.Path("metrics.path").Data().(string)
+	if ok && path != "" {
+		return path
+	}
+
+	return "/metrics"
+}
+
+type metricsToolKey struct{}
+
+// metricsToolLabel is set by a tool's subrouter so that the belt's middleware can label a request with the tool
+type metricsToolLabel struct {
+	name string
+}
+
+// metricsMiddleware records the count and latency of each request to the router
+func (b *Belt) metricsMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		label := &metricsToolLabel{name: beltMetricsToolLabel}
+		sw := &statusResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+
+		start := time.Now()
+		next.ServeHTTP(sw, r.WithContext(context.WithValue(r.Context(), metricsToolKey{}, label)))
+
+		b.metrics.httpRequests.Inc(label.name, r.Method, strconv.Itoa(sw.statusCode))
+		b.metrics.httpRequestDuration.Observe(time.Since(start).Seconds(), label.name, r.Method)
+	})
+}
+
+// metricsToolMiddleware is used on each tool's subrouter to label requests matching the tool's routes
+func metricsToolMiddleware(toolName string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if label, ok := r.Context().Value(metricsToolKey{}).(*metricsToolLabel); ok {
+				label.name = toolName
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+type statusResponseWriter struct {
+	http.ResponseWriter
+	statusCode int
+}
+
+func (sw *statusResponseWriter) WriteHeader(code int) {
+	sw.statusCode = code
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+// Flush flushes the underlying writer, so that streamed responses are not held up by the middleware
+func (sw *statusResponseWriter) Flush() {
+	if flusher, ok := sw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Hijack takes over the connection of the underlying writer, for websockets and other upgraded connections
+func (sw *statusResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := sw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer does not support hijacking")
+	}
+
+	return hijacker.Hijack()
+}
+
+// Unwrap returns the underlying writer, it's used by http.ResponseController
+func (sw *statusResponseWriter) Unwrap() http.ResponseWriter {
+	return sw.ResponseWriter
+}
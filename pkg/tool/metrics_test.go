@@ -0,0 +1,84 @@
+package tool_test
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/example"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+func TestMetrics(t *testing.T) {
+	tb := tool.NewBelt()
+
+	err := tb.AddTool(context.Background(), &example.HelloWorld{})
+	require.NoError(t, err)
+
+	count := 0
+	err = tb.AddTool(context.Background(), &example.JobsTool{Count: &count})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/example-hello-world/", nil)
+	tb.Router.ServeHTTP(httptest.NewRecorder(), req)
+
+	_, err = tb.RunJobNow(context.Background(), "jobs", "example-job")
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	err = tb.Metrics().WriteText(&buf)
+	require.NoError(t, err)
+
+	require.Contains(t, buf.String(), `toolbelt_http_requests_total{tool="hello-world",method="GET",status="200"} 1`)
+	require.Contains(t, buf.String(), `toolbelt_job_runs_total{tool="jobs",job="example-job",outcome="succeeded"} 1`)
+}
+
+func TestMetricsUnmatchedRoutes(t *testing.T) {
+	tb := tool.NewBelt()
+
+	err := tb.AddTool(context.Background(), &example.HelloWorld{})
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodGet, "/missing", nil)
+	rec := httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusNotFound, rec.Code)
+
+	req = httptest.NewRequest(http.MethodDelete, "/healthz", nil)
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	// the metrics are served by the router without starting the server
+	req = httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	rec = httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	require.Contains(t, rec.Body.String(), `toolbelt_http_requests_total{tool="toolbelt",method="GET",status="404"} 1`)
+	require.Contains(t, rec.Body.String(), `toolbelt_http_requests_total{tool="toolbelt",method="DELETE",status="405"} 1`)
+}
+
+func TestMetricsFlush(t *testing.T) {
+	tb := tool.NewBelt()
+
+	flushed := false
+	tb.Router.HandleFunc("/stream", func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if ok {
+			flusher.Flush()
+			flushed = true
+		}
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/stream", nil)
+	rec := httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.True(t, flushed)
+	require.True(t, rec.Flushed)
+}
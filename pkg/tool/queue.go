@@ -0,0 +1,450 @@
+package tool
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+	"github.com/lib/pq"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+const (
+	// taskStatusPending is the status of tasks waiting to be processed
+	taskStatusPending = "pending"
+	// taskStatusRunning is the status of tasks claimed by a worker, they are claimed again once their visibility
+	// timeout passes. Running tasks don't count towards the dedupe key, so a task can be enqueued again while it is
+	// being processed.
+	taskStatusRunning = "running"
+	// taskStatusDead is the status of tasks which failed on every attempt
+	taskStatusDead = "dead"
+)
+
+// taskRetryPolicy sets the delay between attempts to process a task
+var taskRetryPolicy = apis.RetryPolicy{
+	Backoff:         apis.BackoffExponential,
+	InitialInterval: 10 * time.Second,
+	MaxInterval:     10 * time.Minute,
+	Jitter:          0.1,
+}
+
+// queueConfig is the config of the belt's task queue, set with queue
+//
+//	queue:
+//	  workers: 2
+//	  pollInterval: 1s
+//	  visibilityTimeout: 5m
+//	  maxAttempts: 5
+type queueConfig struct {
+	// workers is the number of tasks processed at once
+	workers int
+	// pollInterval is how often workers check for tasks when the queue is empty
+	pollInterval time.Duration
+	// visibilityTimeout is how long a task is hidden from other workers once claimed, the task's handler must return
+	// within this time or the task will be processed again
+	visibilityTimeout time.Duration
+	// maxAttempts is the default number of attempts made to process a task before it's dead-lettered
+	maxAttempts int
+}
+
+func (b *Belt) queueConfig() queueConfig {
+	config := gabs.Wrap(b.getConfig())
+
+	cfg := queueConfig{
+		workers:           configInt(config.Search("queue", "workers")),
+		pollInterval:      configDuration(config.Search("queue", "pollInterval"), time.Second),
+		visibilityTimeout: configDuration(config.Search("queue", "visibilityTimeout"), 5*time.Minute),
+		maxAttempts:       configInt(config.Search("queue", "maxAttempts")),
+	}
+
+	if cfg.workers < 1 {
+		cfg.workers = 2
+	}
+	if cfg.maxAttempts < 1 {
+		cfg.maxAttempts = 5
+	}
+
+	return cfg
+}
+
+// EnqueueFunc returns a function which the named tool can use to enqueue tasks
+func (b *Belt) EnqueueFunc(toolName string) apis.EnqueueFunc {
+	return func(ctx context.Context, kind string, payload any, opts apis.EnqueueOptions) error {
+		return b.EnqueueTask(ctx, toolName, kind, payload, opts)
+	}
+}
+
+// EnqueueTask adds a task to the belt's task queue to be processed by the named tool's handler for the kind. Tasks
+// with a dedupe key are not enqueued if a task of the same kind and key is already waiting to be processed, tasks which
+// are being processed don't prevent the same task being enqueued again.
+func (b *Belt) EnqueueTask(ctx context.Context, toolName, kind string, payload any, opts apis.EnqueueOptions) error {
+	if b.db == nil {
+		return fmt.Errorf("task queue requires a database but none was provided")
+	}
+
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return err
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to encode payload for task %s/%s: %w", toolName, kind, err)
+	}
+
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts < 1 {
+		maxAttempts = b.queueConfig().maxAttempts
+	}
+
+	_, err = b.db.ExecContext(
+		ctx,
+		`INSERT INTO toolbelt.tasks (tool_name, kind, payload, dedupe_key, max_attempts, run_at)
+		VALUES ($1, $2, $3, $4, $5, now() + make_interval(secs => $6))
+		ON CONFLICT (tool_name, kind, dedupe_key) WHERE status = 'pending' AND dedupe_key IS NOT NULL DO NOTHING`,
+		toolName, kind, string(payloadJSON),
+		sql.NullString{String: opts.DedupeKey, Valid: opts.DedupeKey != ""},
+		maxAttempts, opts.Delay.Seconds(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to enqueue task %s/%s: %w", toolName, kind, err)
+	}
+
+	// wake a waiting worker in this belt rather than waiting for the next poll
+	if opts.Delay <= 0 {
+		select {
+		case b.taskNotify <- struct{}{}:
+		default:
+		}
+	}
+
+	return nil
+}
+
+// RunTaskWorkers processes tasks for the belt's tools until ctx is cancelled, it returns once the tasks in progress
+// have finished. Several belts sharing a database can run workers, each task is claimed by one worker at a time.
+func (b *Belt) RunTaskWorkers(ctx context.Context) {
+	if len(b.queueHandlers) == 0 {
+		return
+	}
+
+	if b.db == nil {
+		log.Println("task queue requires a database but none was provided, tasks will not be processed")
+		return
+	}
+
+	cfg := b.queueConfig()
+
+	log.Printf("task worker started with %d workers", cfg.workers)
+
+	var wg sync.WaitGroup
+	for i := 0; i < cfg.workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			b.taskWorker(ctx)
+		}()
+	}
+	wg.Wait()
+
+	log.Println("task worker stopped")
+}
+
+// taskWorker processes tasks one at a time, waiting for new tasks when the queue is empty
+func (b *Belt) taskWorker(ctx context.Context) {
+	for ctx.Err() == nil {
+		processed, err := b.processNextTask(ctx)
+		if err != nil {
+			log.Printf("failed to process task: %s", b.redactor.Redact(err.Error()))
+		}
+		if processed && err == nil {
+			continue
+		}
+
+		timer := time.NewTimer(b.queueConfig().pollInterval)
+		select {
+		case <-ctx.Done():
+		case <-b.taskNotify:
+		case <-timer.C:
+		}
+		timer.Stop()
+	}
+}
+
+// processNextTask claims and processes the next task which is due, it returns false if no task was due
+func (b *Belt) processNextTask(ctx context.Context) (bool, error) {
+	cfg := b.queueConfig()
+
+	task, err := b.claimTask(ctx, cfg.visibilityTimeout)
+	if err != nil {
+		return false, err
+	}
+	if task == nil {
+		return false, nil
+	}
+
+	taskRef := fmt.Sprintf("%s/%s/%d", task.ToolName, task.Kind, task.ID)
+
+	// the handler must finish before the task becomes visible to other workers again
+	handlerCtx, cancel := context.WithTimeout(ctx, cfg.visibilityTimeout)
+	defer cancel()
+
+	handlerErr := b.runTaskHandler(handlerCtx, *task)
+
+	// the result is stored even if the belt is shutting down, so a separate context is used
+	finishCtx, finishCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer finishCancel()
+
+	if handlerErr == nil {
+		b.metrics.tasksProcessed.Inc(task.ToolName, task.Kind, "succeeded")
+		return true, b.completeTask(finishCtx, *task)
+	}
+
+	message := b.redactor.Redact(handlerErr.Error())
+
+	if task.Attempt >= task.MaxAttempts {
+		log.Printf("task %q failed on attempt %d of %d, dead-lettering: %s", taskRef, task.Attempt, task.MaxAttempts, message)
+		b.metrics.tasksProcessed.Inc(task.ToolName, task.Kind, "dead_lettered")
+		return true, b.deadLetterTask(finishCtx, *task, message)
+	}
+
+	delay := retryDelay(taskRetryPolicy, task.Attempt)
+	log.Printf(
+		"task %q failed on attempt %d of %d, retrying in %s: %s",
+		taskRef, task.Attempt, task.MaxAttempts, delay, message,
+	)
+	b.metrics.tasksProcessed.Inc(task.ToolName, task.Kind, "retried")
+
+	return true, b.retryTask(finishCtx, *task, delay, message)
+}
+
+// runTaskHandler runs the handler for the task's kind, returning an error if the handler fails or panics
+func (b *Belt) runTaskHandler(ctx context.Context, task apis.Task) (err error) {
+	handler, ok := b.queueHandlers[task.ToolName][task.Kind]
+	if !ok {
+		return fmt.Errorf("tool %s has no handler for tasks of kind %q", task.ToolName, task.Kind)
+	}
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("handler panicked: %v", p)
+		}
+	}()
+
+	return handler(ctx, task)
+}
+
+// claimTask claims the next due task for one of the belt's tools, hiding it from other workers until the visibility
+// timeout passes. Tasks whose last attempt passed its visibility timeout without finishing are dead-lettered rather
+// than claimed. It returns nil if no task is due.
+func (b *Belt) claimTask(ctx context.Context, visibilityTimeout time.Duration) (*apis.Task, error) {
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	toolNames := make([]string, 0, len(b.queueHandlers))
+	for toolName := range b.queueHandlers {
+		toolNames = append(toolNames, toolName)
+	}
+
+	_, err = b.db.ExecContext(
+		ctx,
+		`UPDATE toolbelt.tasks
+		SET status = 'dead', last_error = $2, updated_at = now()
+		WHERE status IN ('pending', 'running') AND run_at <= now() AND attempts >= max_attempts
+			AND tool_name = ANY($1)`,
+		pq.Array(toolNames), "visibility timeout passed before the last attempt finished",
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to dead-letter timed out tasks: %w", err)
+	}
+
+	var task apis.Task
+	var payload []byte
+	err = b.db.QueryRowContext(
+		ctx,
+		`UPDATE toolbelt.tasks
+		SET status = 'running', attempts = attempts + 1, run_at = now() + make_interval(secs => $1),
+			updated_at = now()
+		WHERE id = (
+			SELECT id FROM toolbelt.tasks
+			WHERE status IN ('pending', 'running') AND run_at <= now() AND attempts < max_attempts
+				AND tool_name = ANY($2)
+			ORDER BY run_at, id
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, tool_name, kind, payload, attempts, max_attempts, created_at`,
+		visibilityTimeout.Seconds(), pq.Array(toolNames),
+	).Scan(&task.ID, &task.ToolName, &task.Kind, &payload, &task.Attempt, &task.MaxAttempts, &task.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim task: %w", err)
+	}
+
+	task.Payload = payload
+
+	return &task, nil
+}
+
+// the following updates only apply while the task is still claimed by this attempt, if the visibility timeout passed
+// and the task was claimed again, the later attempt owns the task
+
+func (b *Belt) completeTask(ctx context.Context, task apis.Task) error {
+	_, err := b.db.ExecContext(
+		ctx,
+		`DELETE FROM toolbelt.tasks WHERE id = $1 AND attempts = $2`,
+		task.ID, task.Attempt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to complete task %d: %w", task.ID, err)
+	}
+
+	return nil
+}
+
+func (b *Belt) retryTask(ctx context.Context, task apis.Task, delay time.Duration, message string) error {
+	_, err := b.db.ExecContext(
+		ctx,
+		`UPDATE toolbelt.tasks
+		SET status = $5, run_at = now() + make_interval(secs => $3), last_error = $4, updated_at = now()
+		WHERE id = $1 AND attempts = $2`,
+		task.ID, task.Attempt, delay.Seconds(), message, taskStatusPending,
+	)
+	if isDedupeConflict(err) {
+		// a task with the same dedupe key was enqueued while this one was running, it is left to do the work
+		log.Printf("task %d failed and a task with the same dedupe key is pending, dropping the retry", task.ID)
+
+		_, err = b.db.ExecContext(ctx, `DELETE FROM toolbelt.tasks WHERE id = $1 AND attempts = $2`, task.ID, task.Attempt)
+		if err != nil {
+			return fmt.Errorf("failed to drop retry of task %d: %w", task.ID, err)
+		}
+
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to schedule retry of task %d: %w", task.ID, err)
+	}
+
+	return nil
+}
+
+// isDedupeConflict returns true if err is from setting a task as pending when a pending task with the same dedupe key
+// already exists
+func isDedupeConflict(err error) bool {
+	var pqErr *pq.Error
+
+	return errors.As(err, &pqErr) && pqErr.Code == "23505" && pqErr.Constraint == "tasks_pending_dedupe_key_idx"
+}
+
+func (b *Belt) deadLetterTask(ctx context.Context, task apis.Task, message string) error {
+	_, err := b.db.ExecContext(
+		ctx,
+		`UPDATE toolbelt.tasks
+		SET status = $3, last_error = $4, updated_at = now()
+		WHERE id = $1 AND attempts = $2`,
+		task.ID, task.Attempt, taskStatusDead, message,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to dead-letter task %d: %w", task.ID, err)
+	}
+
+	return nil
+}
+
+// DeadTask is a task which failed on every attempt, along with the error from the last attempt
+type DeadTask struct {
+	apis.Task
+
+	Error string
+}
+
+// DeadTasks returns the named tool's dead-lettered tasks, newest first. At most limit tasks are returned.
+func (b *Belt) DeadTasks(ctx context.Context, toolName string, limit uint) ([]DeadTask, error) {
+	if b.db == nil {
+		return nil, fmt.Errorf("task queue requires a database but none was provided")
+	}
+
+	err := b.beltDatabaseMigrate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := b.db.QueryContext(
+		ctx,
+		`SELECT id, tool_name, kind, payload, attempts, max_attempts, created_at, last_error
+		FROM toolbelt.tasks
+		WHERE tool_name = $1 AND status = $2
+		ORDER BY updated_at DESC
+		LIMIT $3`,
+		toolName, taskStatusDead, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to select dead tasks: %w", err)
+	}
+	defer rows.Close()
+
+	var tasks []DeadTask
+	for rows.Next() {
+		var task DeadTask
+		var payload []byte
+		err := rows.Scan(
+			&task.ID, &task.ToolName, &task.Kind, &payload, &task.Attempt, &task.MaxAttempts, &task.CreatedAt,
+			&task.Error,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan dead task: %w", err)
+		}
+		task.Payload = payload
+
+		tasks = append(tasks, task)
+	}
+
+	return tasks, rows.Err()
+}
+
+// RequeueDeadTask returns a dead-lettered task to the queue with its attempts reset. If a task with the same dedupe key
+// is already pending, the dead task is removed instead and the pending task is left to do the work.
+func (b *Belt) RequeueDeadTask(ctx context.Context, id int64) error {
+	if b.db == nil {
+		return fmt.Errorf("task queue requires a database but none was provided")
+	}
+
+	result, err := b.db.ExecContext(
+		ctx,
+		`UPDATE toolbelt.tasks
+		SET status = $2, attempts = 0, run_at = now(), updated_at = now()
+		WHERE id = $1 AND status = $3`,
+		id, taskStatusPending, taskStatusDead,
+	)
+	if isDedupeConflict(err) {
+		result, err = b.db.ExecContext(
+			ctx,
+			`DELETE FROM toolbelt.tasks WHERE id = $1 AND status = $2`,
+			id, taskStatusDead,
+		)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to requeue task %d: %w", id, err)
+	}
+
+	requeued, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to check requeued task %d: %w", id, err)
+	}
+	if requeued == 0 {
+		return fmt.Errorf("failed to find dead task %d", id)
+	}
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package tool_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+type queueTool struct {
+	enqueue apis.EnqueueFunc
+}
+
+func (q *queueTool) Name() string { return "queue-tool" }
+
+func (q *queueTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Queue: true} }
+
+func (q *queueTool) SetConfig(config map[string]any) error { return nil }
+
+func (q *queueTool) QueueHandlers() map[string]apis.TaskHandler {
+	return map[string]apis.TaskHandler{
+		"noop": func(ctx context.Context, task apis.Task) error { return nil },
+	}
+}
+
+func (q *queueTool) QueueFuncSet(enqueue apis.EnqueueFunc) { q.enqueue = enqueue }
+
+func TestQueueRequiresDatabase(t *testing.T) {
+	tb := tool.NewBelt()
+
+	err := tb.AddTool(context.Background(), &queueTool{})
+	require.ErrorContains(t, err, "requires a database")
+
+	err = tb.EnqueueTask(context.Background(), "queue-tool", "noop", nil, apis.EnqueueOptions{})
+	require.ErrorContains(t, err, "requires a database")
+}
@@ -0,0 +1,172 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/config"
+)
+
+// configReloadDelay is how long the config file watcher waits for changes to settle before reloading
+const configReloadDelay = 250 * time.Millisecond
+
+// ReloadConfig replaces the belt config while the belt is running. ConfigReload is called on each reloadable tool
+// whose section of the config has changed. The new config of every changed tool is validated first, if any is invalid
+// the reload is rejected and the current config is kept. Tools which don't support reloading keep their current
+// section of the config until the belt is restarted.
+func (b *Belt) ReloadConfig(ctx context.Context, newConfig map[string]any) error {
+	b.reloadMu.Lock()
+	defer b.reloadMu.Unlock()
+
+	oldConfig := b.getConfig()
+
+	// newConfig is copied so that the sections of tools which can't be reloaded can be replaced with their current ones
+	mergedConfig := make(map[string]any, len(newConfig))
+	for k, v := range newConfig {
+		mergedConfig[k] = v
+	}
+
+	type change struct {
+		tool   apis.Tool
+		config map[string]any
+	}
+
+	var changes []change
+	validationErr := &config.ValidationError{}
+
+	for _, t := range b.tools {
+		if !t.FeatureSet().Config {
+			continue
+		}
+
+		if reflect.DeepEqual(oldConfig[t.Name()], newConfig[t.Name()]) {
+			continue
+		}
+
+		if _, ok := t.(apis.ReloadableTool); !ok {
+			log.Printf(
+				"config for tool %q changed, the tool does not support reloading and keeps its current config until "+
+					"the belt is restarted", t.Name(),
+			)
+
+			if section, ok := oldConfig[t.Name()]; ok {
+				mergedConfig[t.Name()] = section
+			} else {
+				delete(mergedConfig, t.Name())
+			}
+
+			continue
+		}
+
+		// the new config is validated into a new struct, the tool's own config struct is left for the tool to update
+		var target any
+		if typedConfigTool, ok := t.(apis.TypedConfigTool); ok {
+			target = reflect.New(reflect.TypeOf(typedConfigTool.ConfigStruct()).Elem()).Interface()
+		}
+
+		toolConfig, err := b.decodeToolConfig(ctx, t.Name(), newConfig, target)
+		if err != nil {
+			addConfigError(validationErr, t.Name(), err)
+			continue
+		}
+
+		changes = append(changes, change{tool: t, config: toolConfig})
+	}
+
+	if err := validationErr.ErrorOrNil(); err != nil {
+		return b.redactor.RedactError(fmt.Errorf("rejected config reload: %w", err))
+	}
+
+	b.SetConfig(mergedConfig)
+
+	var errs []string
+	for _, c := range changes {
+		err := c.tool.(apis.ReloadableTool).ConfigReload(c.config)
+		if err != nil {
+			errs = append(errs, b.redactor.Redact(fmt.Sprintf("failed to reload config for tool %s: %s", c.tool.Name(), err)))
+			continue
+		}
+
+		log.Printf("reloaded config for tool %q", c.tool.Name())
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s", strings.Join(errs, ", "))
+	}
+
+	return nil
+}
+
+// WatchConfigFile reloads the belt config from the file at path whenever it changes, until ctx is cancelled. The file
+// is loaded with config.Load, failed reloads are logged and the current config is kept. Changes to other files in the
+// same directory are ignored.
+func (b *Belt) WatchConfigFile(ctx context.Context, path string) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// the directory is watched rather than the file, since editors and Kubernetes config maps replace the file
+	// rather than writing to it
+	err = watcher.Add(filepath.Dir(path))
+	if err != nil {
+		return fmt.Errorf("failed to watch config file: %w", err)
+	}
+
+	var reloadCh <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if !isConfigFileEvent(event, path) {
+				continue
+			}
+			// wait for any other changes to the file to settle before reloading
+			reloadCh = time.After(configReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("error watching config file: %v", err)
+		case <-reloadCh:
+			reloadCh = nil
+
+			newConfig, err := config.Load(path)
+			if err != nil {
+				log.Printf("failed to load config for reload: %s", b.redactor.Redact(err.Error()))
+				continue
+			}
+
+			err = b.ReloadConfig(ctx, newConfig)
+			if err != nil {
+				log.Printf("failed to reload config: %s", b.redactor.Redact(err.Error()))
+			}
+		}
+	}
+}
+
+// configMapDataDir is the symlink Kubernetes replaces to update all the files of a mounted config map at once
+const configMapDataDir = "..data"
+
+// isConfigFileEvent returns true if the event is for the config file at path, rather than another file in its
+// directory. A file renamed over the config file shows as a create event for its name, and config maps are updated by
+// replacing their data directory.
+func isConfigFileEvent(event fsnotify.Event, path string) bool {
+	name := filepath.Base(event.Name)
+
+	return name == filepath.Base(path) || name == configMapDataDir
+}
@@ -0,0 +1,39 @@
+package tool
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// staticConfigTool uses config but doesn't support reloading it
+type staticConfigTool struct{}
+
+func (s *staticConfigTool) Name() string { return "static-config-tool" }
+
+func (s *staticConfigTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Config: true} }
+
+func (s *staticConfigTool) SetConfig(config map[string]any) error { return nil }
+
+func TestReloadConfigKeepsSectionsOfToolsWhichCantReload(t *testing.T) {
+	b := NewBelt()
+	b.SetConfig(map[string]any{
+		"static-config-tool": map[string]any{"value": "original"},
+	})
+
+	err := b.AddTool(context.Background(), &staticConfigTool{})
+	require.NoError(t, err)
+
+	err = b.ReloadConfig(context.Background(), map[string]any{
+		"static-config-tool": map[string]any{"value": "changed"},
+		"jobs":               map[string]any{"drain": map[string]any{"timeout": "1s"}},
+	})
+	require.NoError(t, err)
+
+	config := b.getConfig()
+	require.Equal(t, map[string]any{"value": "original"}, config["static-config-tool"])
+	require.Equal(t, map[string]any{"drain": map[string]any{"timeout": "1s"}}, config["jobs"])
+}
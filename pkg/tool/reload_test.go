@@ -0,0 +1,96 @@
+package tool_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/example"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+func getBody(t *testing.T, tb *tool.Belt, path string) string {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	rec := httptest.NewRecorder()
+	tb.Router.ServeHTTP(rec, req)
+
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	return rec.Body.String()
+}
+
+func TestReloadConfig(t *testing.T) {
+	tb := tool.NewBelt()
+	tb.SetConfig(map[string]any{
+		"typed-config-tool": map[string]any{"greeting": "hello"},
+	})
+
+	err := tb.AddTool(context.Background(), &example.TypedConfigTool{})
+	require.NoError(t, err)
+
+	require.Equal(t, "hello", getBody(t, tb, "/example-typed-config-tool/"))
+
+	err = tb.ReloadConfig(context.Background(), map[string]any{
+		"typed-config-tool": map[string]any{"greeting": "hi", "repeat": 2},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "hihi", getBody(t, tb, "/example-typed-config-tool/"))
+
+	// invalid config is rejected and the current config is kept
+	err = tb.ReloadConfig(context.Background(), map[string]any{
+		"typed-config-tool": map[string]any{"greeting": "hey", "repeat": 100},
+	})
+	require.ErrorContains(t, err, "typed-config-tool.repeat: must be at most 10")
+
+	require.Equal(t, "hihi", getBody(t, tb, "/example-typed-config-tool/"))
+}
+
+func TestWatchConfigFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	err := os.WriteFile(path, []byte("typed-config-tool:\n  greeting: hello\n"), 0o600)
+	require.NoError(t, err)
+
+	tb := tool.NewBelt()
+	tb.SetConfig(map[string]any{
+		"typed-config-tool": map[string]any{"greeting": "hello"},
+	})
+
+	err = tb.AddTool(context.Background(), &example.TypedConfigTool{})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	go func() {
+		err := tb.WatchConfigFile(ctx, path)
+		require.NoError(t, err)
+	}()
+
+	// give the watcher time to start
+	time.Sleep(100 * time.Millisecond)
+
+	err = os.WriteFile(path, []byte("typed-config-tool:\n  greeting: updated\n"), 0o600)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return getBody(t, tb, "/example-typed-config-tool/") == "updated"
+	}, 5*time.Second, 50*time.Millisecond)
+
+	// editors save by renaming a new file over the config file
+	tmpPath := filepath.Join(filepath.Dir(path), ".config.yaml.swp")
+	err = os.WriteFile(tmpPath, []byte("typed-config-tool:\n  greeting: renamed\n"), 0o600)
+	require.NoError(t, err)
+	err = os.Rename(tmpPath, path)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		return getBody(t, tb, "/example-typed-config-tool/") == "renamed"
+	}, 5*time.Second, 50*time.Millisecond)
+}
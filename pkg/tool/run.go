@@ -0,0 +1,140 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// component is a long running part of the belt which is supervised by Run
+type component struct {
+	name string
+	run  func(ctx context.Context) error
+}
+
+// componentResult is the error returned by a component when it stopped
+type componentResult struct {
+	name string
+	err  error
+}
+
+// Run runs the whole belt until ctx is cancelled or the process receives SIGINT or SIGTERM. The belt's tools are
+// started, then the HTTP server, jobs and task workers are run together. The HTTP server listens on server.host and
+// server.port, which default to 0.0.0.0 and 3000.
+//
+// If any component fails, the others are stopped and the first error is returned along with any errors from shutting
+// down the other components and stopping the tools. Otherwise the errors from shutting down are returned together.
+// Secret values are redacted from the returned errors.
+//
+// Shutting down the server, draining the jobs and stopping the tools must all finish within shutdown.timeout of the
+// belt starting to stop. server.timeout.shutdown and jobs.drain.timeout can shorten their phases further.
+func (b *Belt) Run(ctx context.Context) error {
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	err := b.StartTools(ctx)
+	if err != nil {
+		return b.redactor.RedactError(err)
+	}
+
+	// the components and tools share one shutdown deadline, so that shutting down takes no longer than
+	// shutdown.timeout in total
+	deadline := b.newShutdownDeadline()
+
+	// the server is started first so that the belt fails straight away if it can't listen
+	server, err := b.StartServer(b.serverAddr())
+	if err != nil {
+		return b.redactor.RedactError(b.stopToolsAfter(fmt.Errorf("server failed: %w", err), deadline))
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	components := []component{
+		{name: "server", run: func(ctx context.Context) error { return b.serveUntilDone(ctx, server, deadline) }},
+		{name: "jobs", run: func(ctx context.Context) error { return b.runJobs(ctx, deadline) }},
+		{name: "task workers", run: func(ctx context.Context) error {
+			b.RunTaskWorkers(ctx)
+			return nil
+		}},
+	}
+
+	results := make(chan componentResult, len(components))
+	for _, c := range components {
+		go func(c component) {
+			results <- componentResult{name: c.name, err: c.run(runCtx)}
+		}(c)
+	}
+
+	var fatalErr error
+	var shutdownErrs []string
+	for range components {
+		result := <-results
+		if result.err == nil {
+			continue
+		}
+
+		// an error before the belt is stopping is fatal and stops the other components
+		if runCtx.Err() == nil {
+			log.Printf("%s failed, stopping belt: %s", result.name, b.redactor.Redact(result.err.Error()))
+			fatalErr = fmt.Errorf("%s failed: %w", result.name, result.err)
+			cancel()
+			continue
+		}
+
+		log.Printf("%s failed to shut down cleanly: %s", result.name, b.redactor.Redact(result.err.Error()))
+		shutdownErrs = append(shutdownErrs, fmt.Sprintf("%s: %s", result.name, result.err))
+	}
+
+	// tools are stopped once nothing is using them
+	err = b.stopToolsAfter(nil, deadline)
+	if err != nil {
+		shutdownErrs = append(shutdownErrs, err.Error())
+	}
+
+	switch {
+	case fatalErr != nil && len(shutdownErrs) > 0:
+		return b.redactor.RedactError(fmt.Errorf(
+			"%w, and then failed to shut down cleanly: %s", fatalErr, strings.Join(shutdownErrs, ", "),
+		))
+	case fatalErr != nil:
+		return b.redactor.RedactError(fatalErr)
+	case len(shutdownErrs) > 0:
+		return b.redactor.RedactError(
+			fmt.Errorf("failed to shut down cleanly: %s", strings.Join(shutdownErrs, ", ")),
+		)
+	}
+
+	log.Println("belt stopped")
+
+	return nil
+}
+
+// serverAddr returns the address Run serves HTTP requests on, set with server.host and server.port
+func (b *Belt) serverAddr() string {
+	config := gabs.Wrap(b.getConfig())
+
+	host, ok := config.Path("server.host").Data().(string)
+	if !ok {
+		host = "0.0.0.0"
+	}
+
+	// ports may be given as strings or numbers
+	port := "3000"
+	switch value := config.Path("server.port").Data().(type) {
+	case string:
+		port = value
+	case nil:
+	default:
+		port = fmt.Sprint(configInt(config.Path("server.port")))
+	}
+
+	return net.JoinHostPort(host, port)
+}
@@ -0,0 +1,190 @@
+package tool_test
+
+import (
+	"context"
+	"net"
+	"sync"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+// tcpTool records the context it was started with
+type tcpTool struct {
+	mu      sync.Mutex
+	ctx     context.Context
+	started chan struct{}
+}
+
+func (t *tcpTool) Name() string { return "tcp-tool" }
+
+func (t *tcpTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{TCP: true} }
+
+func (t *tcpTool) SetConfig(config map[string]any) error { return nil }
+
+func (t *tcpTool) TCPStart(ctx context.Context) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.ctx = ctx
+	close(t.started)
+
+	return nil
+}
+
+func (t *tcpTool) context() context.Context {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return t.ctx
+}
+
+// slowStopTool doesn't stop until its context is done
+type slowStopTool struct{}
+
+func (s *slowStopTool) Name() string { return "slow-stop-tool" }
+
+func (s *slowStopTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Lifecycle: true} }
+
+func (s *slowStopTool) SetConfig(config map[string]any) error { return nil }
+
+func (s *slowStopTool) LifecycleStart(ctx context.Context) error { return nil }
+
+func (s *slowStopTool) LifecycleStop(ctx context.Context) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+func TestRun(t *testing.T) {
+	t.Run("stops when the context is cancelled", func(t *testing.T) {
+		tb := tool.NewBelt()
+		tb.SetConfig(map[string]any{"server": map[string]any{"host": "127.0.0.1", "port": 0}})
+
+		var events []string
+		tcp := &tcpTool{started: make(chan struct{})}
+		err := tb.AddTools(context.Background(), &lifecycleTool{name: "a", events: &events}, tcp)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- tb.Run(ctx)
+		}()
+
+		<-tcp.started
+		require.NoError(t, tcp.context().Err())
+
+		cancel()
+
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after it was stopped")
+		}
+
+		require.Equal(t, []string{"start a", "stop a"}, events)
+		require.Error(t, tcp.context().Err(), "TCP tools should be stopped with the belt")
+	})
+
+	t.Run("stops on SIGTERM", func(t *testing.T) {
+		tb := tool.NewBelt()
+		tb.SetConfig(map[string]any{"server": map[string]any{"host": "127.0.0.1", "port": "0"}})
+
+		tcp := &tcpTool{started: make(chan struct{})}
+		err := tb.AddTool(context.Background(), tcp)
+		require.NoError(t, err)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- tb.Run(context.Background())
+		}()
+
+		<-tcp.started
+		require.NoError(t, syscall.Kill(syscall.Getpid(), syscall.SIGTERM))
+
+		select {
+		case err := <-errs:
+			require.NoError(t, err)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after SIGTERM")
+		}
+	})
+
+	t.Run("returns the error of a failed component", func(t *testing.T) {
+		// the belt's server can't listen on a port which is in use
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		tb := tool.NewBelt()
+		tb.SetConfig(map[string]any{"server": map[string]any{
+			"host": "127.0.0.1",
+			"port": listener.Addr().(*net.TCPAddr).Port,
+		}})
+
+		var events []string
+		err = tb.AddTool(context.Background(), &lifecycleTool{name: "a", events: &events})
+		require.NoError(t, err)
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- tb.Run(context.Background())
+		}()
+
+		select {
+		case err := <-errs:
+			require.ErrorContains(t, err, "server failed: failed to listen on")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after the server failed")
+		}
+
+		require.Equal(t, []string{"start a", "stop a"}, events)
+	})
+	t.Run("shuts down within the shutdown timeout in total", func(t *testing.T) {
+		tb := tool.NewBelt()
+		tb.SetConfig(map[string]any{
+			"server":   map[string]any{"host": "127.0.0.1", "port": 0},
+			"shutdown": map[string]any{"timeout": "300ms"},
+		})
+
+		job := &drainJob{started: make(chan struct{}, 1), ignoreCancel: true}
+		err := tb.AddTools(context.Background(), &jobsTool{jobs: []apis.Job{job}}, &slowStopTool{})
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- tb.Run(ctx)
+		}()
+
+		select {
+		case <-job.started:
+		case <-time.After(3 * time.Second):
+			t.Fatal("job did not run")
+		}
+
+		// the job drain and stopping the tool both use up the whole timeout, but must share it
+		stoppedAt := time.Now()
+		cancel()
+
+		select {
+		case err := <-errs:
+			require.ErrorContains(t, err, "jobs still running")
+			require.ErrorContains(t, err, "failed to stop tool slow-stop-tool")
+		case <-time.After(5 * time.Second):
+			t.Fatal("Run did not return after it was stopped")
+		}
+
+		require.Less(t, time.Since(stoppedAt), 500*time.Millisecond)
+	})
+}
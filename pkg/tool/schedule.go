@@ -0,0 +1,80 @@
+package tool
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron"
+)
+
+// scheduleTimezonePrefixes are the prefixes which set the timezone of a schedule, e.g. CRON_TZ=Europe/London 0 0 9 * * *
+var scheduleTimezonePrefixes = []string{"CRON_TZ=", "TZ="}
+
+// parseSchedule parses a job schedule. Schedules use the six field cron syntax starting with seconds, or descriptors
+// such as @daily and @every 15m. Schedules are evaluated in the local timezone unless they start with CRON_TZ= or TZ=
+// followed by the name of a timezone.
+func parseSchedule(spec string) (cron.Schedule, error) {
+	spec = strings.TrimSpace(spec)
+
+	var location *time.Location
+	for _, prefix := range scheduleTimezonePrefixes {
+		if !strings.HasPrefix(spec, prefix) {
+			continue
+		}
+
+		name, rest, ok := strings.Cut(strings.TrimPrefix(spec, prefix), " ")
+		if !ok {
+			return nil, fmt.Errorf("schedule %q has a timezone but no schedule", spec)
+		}
+
+		var err error
+		location, err = time.LoadLocation(name)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q has an invalid timezone: %w", spec, err)
+		}
+
+		spec = strings.TrimSpace(rest)
+		break
+	}
+
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+	}
+
+	if location == nil {
+		return schedule, nil
+	}
+
+	return &timezoneSchedule{location: location, schedule: schedule}, nil
+}
+
+// timezoneSchedule evaluates a schedule in a fixed timezone rather than the timezone of the belt
+type timezoneSchedule struct {
+	location *time.Location
+	schedule cron.Schedule
+}
+
+func (s *timezoneSchedule) Next(t time.Time) time.Time {
+	return s.schedule.Next(t.In(s.location))
+}
+
+// scheduleLateness is how late a schedule may fire and still be matched to the occurrence it fired for
+const scheduleLateness = 5 * time.Second
+
+// scheduledOccurrence returns the occurrence of the schedule which fired at now. Schedules fire on whole seconds at or
+// just after each occurrence, so the most recent occurrence within scheduleLateness is used, and every belt sharing a
+// database finds the same occurrence. Schedules without an occurrence in that window, such as @every schedules, use
+// the time truncated to the second.
+func scheduledOccurrence(schedule cron.Schedule, now time.Time) time.Time {
+	second := now.Truncate(time.Second)
+
+	for candidate := second; now.Sub(candidate) <= scheduleLateness; candidate = candidate.Add(-time.Second) {
+		if schedule.Next(candidate.Add(-time.Second)).Equal(candidate) {
+			return candidate
+		}
+	}
+
+	return second
+}
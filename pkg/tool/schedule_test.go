@@ -0,0 +1,95 @@
+package tool_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+type scheduledJob struct {
+	schedule string
+}
+
+func (s *scheduledJob) Name() string { return "scheduled-job" }
+
+func (s *scheduledJob) Run(ctx context.Context) error { return nil }
+
+func (s *scheduledJob) Timeout() time.Duration { return time.Second }
+
+func (s *scheduledJob) Schedule() string { return s.schedule }
+
+func TestJobSchedules(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		schedule    string
+		expectError bool
+		checkNext   func(t *testing.T, next time.Time)
+	}{
+		"six fields": {
+			schedule: "0 30 * * * *",
+			checkNext: func(t *testing.T, next time.Time) {
+				require.Equal(t, 30, next.Minute())
+			},
+		},
+		"descriptor": {
+			schedule: "@every 15m",
+			checkNext: func(t *testing.T, next time.Time) {
+				require.WithinDuration(t, time.Now().Add(15*time.Minute), next, time.Minute)
+			},
+		},
+		"timezone": {
+			schedule: "CRON_TZ=Asia/Tokyo 0 0 9 * * *",
+			checkNext: func(t *testing.T, next time.Time) {
+				require.Equal(t, 9, next.In(tokyo).Hour())
+			},
+		},
+		"timezone with descriptor": {
+			schedule: "TZ=Asia/Tokyo @daily",
+			checkNext: func(t *testing.T, next time.Time) {
+				require.Equal(t, 0, next.In(tokyo).Hour())
+			},
+		},
+		"invalid schedule": {
+			schedule:    "not a schedule",
+			expectError: true,
+		},
+		"invalid timezone": {
+			schedule:    "CRON_TZ=Nowhere/Special 0 0 9 * * *",
+			expectError: true,
+		},
+		"timezone without schedule": {
+			schedule:    "CRON_TZ=Asia/Tokyo",
+			expectError: true,
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tb := tool.NewBelt()
+
+			err := tb.AddTool(
+				context.Background(),
+				&jobsTool{jobs: []apis.Job{&scheduledJob{schedule: testCase.schedule}}},
+			)
+			if testCase.expectError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			infos := tb.Inspect(context.Background())
+			require.Len(t, infos, 1)
+			require.Len(t, infos[0].Jobs, 1)
+			require.NotNil(t, infos[0].Jobs[0].NextRun)
+
+			testCase.checkNext(t, *infos[0].Jobs[0].NextRun)
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package tool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+)
+
+// secretPrefix marks a config value as a reference to a secret
+const secretPrefix = "secret://"
+
+// AddSecretProvider adds a provider which can be used to resolve secret references in tool config. The belt has file
+// and env providers by default, adding a provider with the same name replaces it.
+func (b *Belt) AddSecretProvider(provider apis.SecretProvider) {
+	b.secretProviders[provider.Name()] = provider
+}
+
+// resolveSecrets returns a copy of the value with all secret references resolved, the belt config is not modified so
+// that the references rather than the values are kept there. Resolved values are redacted from the belt's output.
+func (b *Belt) resolveSecrets(ctx context.Context, key string, value any) (any, error) {
+	switch v := value.(type) {
+	case string:
+		if !strings.HasPrefix(v, secretPrefix) {
+			return v, nil
+		}
+
+		providerName, path, _ := strings.Cut(strings.TrimPrefix(v, secretPrefix), "/")
+
+		provider, ok := b.secretProviders[providerName]
+		if !ok {
+			return nil, fmt.Errorf("failed to find secret provider %q for %s", providerName, key)
+		}
+
+		secret, err := provider.Resolve(ctx, path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to resolve secret for %s: %w", key, err)
+		}
+
+		b.redactor.Add(secret)
+
+		return secret, nil
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		resolved := make(map[string]any, len(v))
+		for _, k := range keys {
+			item, err := b.resolveSecrets(ctx, key+"."+k, v[k])
+			if err != nil {
+				return nil, err
+			}
+			resolved[k] = item
+		}
+
+		return resolved, nil
+	case []any:
+		resolved := make([]any, len(v))
+		for i, item := range v {
+			resolvedItem, err := b.resolveSecrets(ctx, fmt.Sprintf("%s[%d]", key, i), item)
+			if err != nil {
+				return nil, err
+			}
+			resolved[i] = resolvedItem
+		}
+
+		return resolved, nil
+	default:
+		return value, nil
+	}
+}
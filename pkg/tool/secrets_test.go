@@ -0,0 +1,168 @@
+package tool_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/secrets"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+type secretsTool struct {
+	config struct {
+		Token    string `config:"token,required"`
+		Password string `config:"password,required"`
+	}
+}
+
+func (s *secretsTool) Name() string { return "secrets-tool" }
+
+func (s *secretsTool) FeatureSet() apis.FeatureSet {
+	return apis.FeatureSet{Config: true, Jobs: true}
+}
+
+func (s *secretsTool) ConfigStruct() any { return &s.config }
+
+func (s *secretsTool) SetConfig(config map[string]any) error { return nil }
+
+func (s *secretsTool) Jobs() ([]apis.Job, error) {
+	return []apis.Job{&leakyJob{token: s.config.Token}}, nil
+}
+
+// leakyJob fails with an error containing a secret
+type leakyJob struct {
+	token string
+}
+
+func (l *leakyJob) Name() string { return "leaky-job" }
+
+func (l *leakyJob) Run(ctx context.Context) error {
+	return fmt.Errorf("request with token %s was rejected", l.token)
+}
+
+func (l *leakyJob) Timeout() time.Duration { return time.Second }
+
+func (l *leakyJob) Schedule() string { return "0 0 0 * * *" }
+
+func TestSecretReferences(t *testing.T) {
+	t.Setenv("TOOLBELT_TEST_TOKEN", "token-value")
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "password"), []byte("password-value\n"), 0o600)
+	require.NoError(t, err)
+
+	tb := tool.NewBelt()
+	tb.AddSecretProvider(&secrets.FileProvider{Dir: dir})
+
+	cfg := map[string]any{
+		"secrets-tool": map[string]any{
+			"token":    "secret://env/TOOLBELT_TEST_TOKEN",
+			"password": "secret://file/password",
+		},
+	}
+	tb.SetConfig(cfg)
+
+	secretsTool := &secretsTool{}
+	err = tb.AddTool(context.Background(), secretsTool)
+	require.NoError(t, err)
+
+	require.Equal(t, "token-value", secretsTool.config.Token)
+	require.Equal(t, "password-value", secretsTool.config.Password)
+
+	// the belt config keeps the references rather than the values
+	require.Equal(t, "secret://env/TOOLBELT_TEST_TOKEN", cfg["secrets-tool"].(map[string]any)["token"])
+
+	run, err := tb.RunJobNow(context.Background(), "secrets-tool", "leaky-job")
+	require.NoError(t, err)
+	require.Equal(t, "request with token [redacted] was rejected", run.Error)
+}
+
+// rejectingTool fails to set its config with an error containing the config
+type rejectingTool struct{}
+
+func (r *rejectingTool) Name() string { return "rejecting-tool" }
+
+func (r *rejectingTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{Config: true} }
+
+func (r *rejectingTool) SetConfig(config map[string]any) error {
+	return fmt.Errorf("token %v is invalid", config["token"])
+}
+
+func TestSecretRedactionInToolErrors(t *testing.T) {
+	t.Setenv("TOOLBELT_TEST_TOKEN", "token-value")
+
+	tb := tool.NewBelt()
+	tb.SetConfig(map[string]any{
+		"rejecting-tool": map[string]any{"token": "secret://env/TOOLBELT_TEST_TOKEN"},
+	})
+
+	err := tb.AddTool(context.Background(), &rejectingTool{})
+	require.EqualError(t, err, "failed to set config for tool rejecting-tool: token [redacted] is invalid")
+}
+
+func TestFileProviderOutsideDir(t *testing.T) {
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "outside"), []byte("outside-value"), 0o600)
+	require.NoError(t, err)
+
+	secretsDir := filepath.Join(dir, "secrets")
+	require.NoError(t, os.Mkdir(secretsDir, 0o700))
+
+	provider := &secrets.FileProvider{Dir: secretsDir}
+
+	_, err = provider.Resolve(context.Background(), "../outside")
+	require.ErrorContains(t, err, "is outside of")
+
+	_, err = provider.Resolve(context.Background(), "nested/../../outside")
+	require.ErrorContains(t, err, "is outside of")
+}
+
+// leakyRunner fails jobs with an error containing their token
+type leakyRunner struct{}
+
+func (l *leakyRunner) Name() string { return "leaky" }
+
+func (l *leakyRunner) Configure(config map[string]any) error { return nil }
+
+func (l *leakyRunner) RunJob(job apis.ExternalJob) error {
+	return fmt.Errorf("token %v was rejected", job.Config()["token"])
+}
+
+func TestSecretRedactionInExternalJobLogs(t *testing.T) {
+	t.Setenv("TOOLBELT_TEST_TOKEN", "token-value")
+	t.Setenv("TOOLBELT_TEST_PASSWORD", "password-value")
+
+	tb := tool.NewBelt()
+	tb.AddExternalJobRunner(&leakyRunner{})
+	tb.SetConfig(map[string]any{
+		"secrets-tool": map[string]any{
+			"token":    "secret://env/TOOLBELT_TEST_TOKEN",
+			"password": "secret://env/TOOLBELT_TEST_PASSWORD",
+		},
+	})
+
+	err := tb.AddTool(context.Background(), &secretsTool{})
+	require.NoError(t, err)
+
+	handle, err := tb.StartExternalJob(
+		context.Background(), "secrets-tool",
+		&externalJob{runner: "leaky", config: map[string]any{"token": "token-value"}},
+	)
+	require.NoError(t, err)
+
+	require.Eventually(t, func() bool {
+		status, err := handle.Status(context.Background())
+		return err == nil && status == apis.ExternalJobFailed
+	}, 5*time.Second, 10*time.Millisecond)
+
+	logs, err := handle.Logs(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, "token [redacted] was rejected", logs)
+}
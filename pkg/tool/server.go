@@ -0,0 +1,255 @@
+package tool
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/Jeffail/gabs/v2"
+)
+
+// Server is the belt's HTTP server, started with StartServer
+type Server struct {
+	belt     *Belt
+	server   *http.Server
+	listener net.Listener
+
+	// redirect serves redirects from HTTP to HTTPS on redirectListener, when TLS and redirects are enabled
+	redirect         *http.Server
+	redirectListener net.Listener
+
+	// stopWatching stops reloading certificates when TLS is enabled
+	stopWatching context.CancelFunc
+
+	// serveErr receives the errors from the servers if they stop serving before they're shut down
+	serveErr chan error
+}
+
+// StartServer listens on addr and serves the belt's router in the background until the server is shut down. Errors
+// listening on addr, such as the port being in use, are returned before any requests are served. A port of 0 listens
+// on a free port, which can be found with Addr.
+//
+// When certificates are set with server.tls the server serves HTTPS, and certificates are reloaded when their files
+// change. Plain HTTP requests are redirected to HTTPS when server.tls.redirectAddr is set.
+func (b *Belt) StartServer(addr string) (*Server, error) {
+	config := gabs.Wrap(b.getConfig())
+
+	tlsCfg, err := b.serverTLS()
+	if err != nil {
+		return nil, err
+	}
+
+	var certs *certificateStore
+	if tlsCfg.enabled() {
+		certs, err = newCertificateStore(tlsCfg)
+		if err != nil {
+			return nil, err
+		}
+
+		b.checkHostCertificates(certs)
+	}
+
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+	}
+
+	writeTimeout := configDuration(config.Path("server.timeout.write"), 30*time.Second)
+	readTimeout := configDuration(config.Path("server.timeout.read"), 30*time.Second)
+
+	s := &Server{
+		belt: b,
+		server: &http.Server{
+			Handler:      b.Router,
+			Addr:         listener.Addr().String(),
+			WriteTimeout: writeTimeout,
+			ReadTimeout:  readTimeout,
+		},
+		listener:     listener,
+		stopWatching: func() {},
+		serveErr:     make(chan error, 2),
+	}
+
+	if certs != nil {
+		s.server.TLSConfig = &tls.Config{
+			GetCertificate: certs.getCertificate,
+			MinVersion:     tls.VersionTLS12,
+		}
+
+		if tlsCfg.RedirectAddr != "" {
+			redirectListener, err := net.Listen("tcp", tlsCfg.RedirectAddr)
+			if err != nil {
+				_ = listener.Close()
+				return nil, fmt.Errorf("failed to listen for HTTP redirects on %s: %w", tlsCfg.RedirectAddr, err)
+			}
+
+			_, httpsPort, _ := net.SplitHostPort(listener.Addr().String())
+
+			s.redirectListener = redirectListener
+			s.redirect = &http.Server{
+				Handler:      httpsRedirectHandler(httpsPort),
+				Addr:         redirectListener.Addr().String(),
+				WriteTimeout: writeTimeout,
+				ReadTimeout:  readTimeout,
+			}
+		}
+
+		var watchCtx context.Context
+		watchCtx, s.stopWatching = context.WithCancel(context.Background())
+		go func() {
+			err := certs.watch(watchCtx)
+			if err != nil {
+				log.Printf("failed to watch certificates, they will not be reloaded: %v", err)
+			}
+		}()
+	}
+
+	go s.serve(func() error {
+		if s.server.TLSConfig != nil {
+			// the certificates are provided by the TLS config
+			return s.server.ServeTLS(listener, "", "")
+		}
+
+		return s.server.Serve(listener)
+	})
+
+	if s.redirect != nil {
+		go s.serve(func() error {
+			return s.redirect.Serve(s.redirectListener)
+		})
+
+		log.Printf("redirecting HTTP requests on %s to HTTPS", s.redirectListener.Addr())
+	}
+
+	b.serverMu.Lock()
+	b.server = s
+	b.serverMu.Unlock()
+
+	b.ready.Store(true)
+
+	if certs != nil {
+		log.Printf("server listening with TLS on %s", listener.Addr())
+	} else {
+		log.Printf("server listening on %s", listener.Addr())
+	}
+
+	return s, nil
+}
+
+// serve runs a server's serve function, reporting the error if the server stops before it's shut down
+func (s *Server) serve(serve func() error) {
+	err := serve()
+	if !errors.Is(err, http.ErrServerClosed) {
+		s.serveErr <- err
+	}
+}
+
+// Addr returns the address the server is listening on
+func (s *Server) Addr() net.Addr {
+	return s.listener.Addr()
+}
+
+// RedirectAddr returns the address on which HTTP requests are redirected to HTTPS, or nil if redirects are not enabled
+func (s *Server) RedirectAddr() net.Addr {
+	if s.redirectListener == nil {
+		return nil
+	}
+
+	return s.redirectListener.Addr()
+}
+
+// Err returns a channel which receives an error if the server stops serving before it's shut down
+func (s *Server) Err() <-chan error {
+	return s.serveErr
+}
+
+// Shutdown stops the server gracefully, requests in progress are given until ctx is done to complete
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.detach()
+
+	log.Println("Shutting down server")
+
+	if s.redirect != nil {
+		err := s.redirect.Shutdown(ctx)
+		if err != nil {
+			_ = s.server.Close()
+			return fmt.Errorf("graceful shutdown of HTTP redirects failed: %w", err)
+		}
+	}
+
+	err := s.server.Shutdown(ctx)
+	if err != nil {
+		return fmt.Errorf("graceful shutdown of server failed: %w", err)
+	}
+
+	log.Println("Server gracefully stopped")
+
+	return nil
+}
+
+// detach marks the belt as no longer ready and stops reloading certificates before the server stops
+func (s *Server) detach() {
+	s.belt.ready.Store(false)
+
+	s.belt.serverMu.Lock()
+	if s.belt.server == s {
+		s.belt.server = nil
+	}
+	s.belt.serverMu.Unlock()
+
+	s.stopWatching()
+}
+
+// close stops the server immediately, without waiting for requests in progress
+func (s *Server) close() {
+	s.detach()
+
+	if s.redirect != nil {
+		_ = s.redirect.Close()
+	}
+	_ = s.server.Close()
+}
+
+// ServerAddr returns the address the belt's server is listening on, or nil if the server is not running
+func (b *Belt) ServerAddr() net.Addr {
+	b.serverMu.Lock()
+	defer b.serverMu.Unlock()
+
+	if b.server == nil {
+		return nil
+	}
+
+	return b.server.Addr()
+}
+
+// serveUntilDone waits until ctx is cancelled and then shuts down the server within the server shutdown timeout, or by
+// the shutdown deadline if that is sooner. An error is returned if the server fails before ctx is cancelled, or fails
+// to shut down in time.
+func (b *Belt) serveUntilDone(ctx context.Context, s *Server, deadline *shutdownDeadline) error {
+	select {
+	case err := <-s.Err():
+		// a listener has failed, so the server is closed rather than shut down gracefully
+		s.close()
+
+		return fmt.Errorf("server stopped unexpectedly: %w", err)
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := deadline.context(b.serverShutdownTimeout())
+	defer cancel()
+
+	return s.Shutdown(shutdownCtx)
+}
+
+// serverShutdownTimeout returns the time allowed for requests in progress to complete when the server is shut down,
+// set with server.timeout.shutdown and defaulting to shutdown.timeout
+func (b *Belt) serverShutdownTimeout() time.Duration {
+	config := gabs.Wrap(b.getConfig())
+
+	return configDuration(config.Path("server.timeout.shutdown"), b.shutdownTimeout())
+}
@@ -0,0 +1,137 @@
+package tool_test
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+// slowTool serves requests which take a second to complete, and signals when a request has started
+type slowTool struct {
+	started chan struct{}
+}
+
+func (s *slowTool) Name() string { return "slow-tool" }
+
+func (s *slowTool) FeatureSet() apis.FeatureSet { return apis.FeatureSet{HTTP: true} }
+
+func (s *slowTool) SetConfig(config map[string]any) error { return nil }
+
+func (s *slowTool) HTTPPath() string { return "slow" }
+
+func (s *slowTool) HTTPHost() string { return "" }
+
+func (s *slowTool) HTTPAttach(router *mux.Router) error {
+	router.HandleFunc("/", func(writer http.ResponseWriter, request *http.Request) {
+		close(s.started)
+		time.Sleep(time.Second)
+	})
+
+	return nil
+}
+
+func TestStartServer(t *testing.T) {
+	tb := tool.NewBelt()
+	require.Nil(t, tb.ServerAddr())
+
+	server, err := tb.StartServer("127.0.0.1:0")
+	require.NoError(t, err)
+
+	addr := server.Addr().(*net.TCPAddr)
+	require.NotZero(t, addr.Port)
+	require.Equal(t, server.Addr(), tb.ServerAddr())
+
+	resp, err := http.Get(fmt.Sprintf("http://%s/readyz", addr))
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+
+	require.NoError(t, server.Shutdown(context.Background()))
+	require.Nil(t, tb.ServerAddr())
+
+	_, err = http.Get(fmt.Sprintf("http://%s/readyz", addr))
+	require.Error(t, err)
+}
+
+func TestStartServerAddressInUse(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+
+	tb := tool.NewBelt()
+
+	_, err = tb.StartServer(listener.Addr().String())
+	require.ErrorContains(t, err, "failed to listen on "+listener.Addr().String())
+	require.Nil(t, tb.ServerAddr())
+}
+
+func TestRunServer(t *testing.T) {
+	t.Run("returns bind errors", func(t *testing.T) {
+		listener, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		defer listener.Close()
+
+		tb := tool.NewBelt()
+
+		var events []string
+		err = tb.AddTool(context.Background(), &lifecycleTool{name: "a", events: &events})
+		require.NoError(t, err)
+
+		port := fmt.Sprint(listener.Addr().(*net.TCPAddr).Port)
+		err = tb.RunServer(context.Background(), "127.0.0.1", port)
+		require.ErrorContains(t, err, "failed to listen on")
+
+		require.Equal(t, []string{"start a", "stop a"}, events)
+	})
+
+	t.Run("returns shutdown errors", func(t *testing.T) {
+		tb := tool.NewBelt()
+		tb.SetConfig(map[string]any{
+			"server": map[string]any{
+				"timeout": map[string]any{"shutdown": "50ms"},
+			},
+		})
+
+		slow := &slowTool{started: make(chan struct{})}
+		err := tb.AddTool(context.Background(), slow)
+		require.NoError(t, err)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+
+		errs := make(chan error, 1)
+		go func() {
+			errs <- tb.RunServer(ctx, "127.0.0.1", "0")
+		}()
+
+		require.Eventually(t, func() bool {
+			return tb.ServerAddr() != nil
+		}, time.Second, 10*time.Millisecond)
+
+		go func() {
+			resp, err := http.Get(fmt.Sprintf("http://%s/slow/", tb.ServerAddr()))
+			if err == nil {
+				resp.Body.Close()
+			}
+		}()
+		<-slow.started
+
+		cancel()
+
+		select {
+		case err := <-errs:
+			require.ErrorContains(t, err, "graceful shutdown of server failed: context deadline exceeded")
+		case <-time.After(5 * time.Second):
+			t.Fatal("RunServer did not return after it was stopped")
+		}
+	})
+}
@@ -0,0 +1,249 @@
+package tool
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/charlieegan3/toolbelt/pkg/apis"
+	"github.com/charlieegan3/toolbelt/pkg/config"
+)
+
+// serverTLSConfig is the config of the server's TLS, set under server.tls. TLS is enabled when a default certificate
+// or any host certificates are set.
+type serverTLSConfig struct {
+	// Cert and Key are the files of the default certificate, which is served when no host certificate matches the
+	// server name sent by the client
+	Cert string `config:"cert"`
+	Key  string `config:"key"`
+	// Hosts holds a certificate for each host of the tools using the HTTPHost feature
+	Hosts []tlsHostConfig `config:"hosts"`
+	// RedirectAddr, if set, is an address on which plain HTTP requests are redirected to HTTPS
+	RedirectAddr string `config:"redirectAddr"`
+}
+
+// tlsHostConfig is the certificate for a host, the host may be a wildcard such as *.example.com
+type tlsHostConfig struct {
+	Host string `config:"host,required" validate:"nonempty"`
+	Cert string `config:"cert,required" validate:"nonempty"`
+	Key  string `config:"key,required" validate:"nonempty"`
+}
+
+func (c serverTLSConfig) enabled() bool {
+	return c.Cert != "" || c.Key != "" || len(c.Hosts) > 0
+}
+
+// serverTLS returns the server's TLS config, set with server.tls
+func (b *Belt) serverTLS() (serverTLSConfig, error) {
+	var cfg serverTLSConfig
+
+	raw, _ := b.getConfig()["server"].(map[string]any)
+	tlsRaw, _ := raw["tls"].(map[string]any)
+
+	err := config.Decode(tlsRaw, &cfg)
+	if err != nil {
+		return cfg, fmt.Errorf("invalid server TLS config: %w", err)
+	}
+
+	if (cfg.Cert == "") != (cfg.Key == "") {
+		return cfg, fmt.Errorf("invalid server TLS config: cert and key must be set together")
+	}
+
+	return cfg, nil
+}
+
+// certificateStore holds the server's certificates and selects one for each connection using the server name sent
+// by the client
+type certificateStore struct {
+	cfg serverTLSConfig
+
+	mu          sync.RWMutex
+	defaultCert *tls.Certificate
+	hosts       map[string]*tls.Certificate
+}
+
+// newCertificateStore loads the certificates in cfg
+func newCertificateStore(cfg serverTLSConfig) (*certificateStore, error) {
+	s := &certificateStore{cfg: cfg}
+
+	err := s.reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
+
+// reload loads the certificates from their files again, the current certificates are kept if any fail to load
+func (s *certificateStore) reload() error {
+	var defaultCert *tls.Certificate
+	if s.cfg.Cert != "" {
+		cert, err := tls.LoadX509KeyPair(s.cfg.Cert, s.cfg.Key)
+		if err != nil {
+			return fmt.Errorf("failed to load default certificate: %w", err)
+		}
+		defaultCert = &cert
+	}
+
+	hosts := make(map[string]*tls.Certificate, len(s.cfg.Hosts))
+	for _, host := range s.cfg.Hosts {
+		cert, err := tls.LoadX509KeyPair(host.Cert, host.Key)
+		if err != nil {
+			return fmt.Errorf("failed to load certificate for host %s: %w", host.Host, err)
+		}
+		hosts[strings.ToLower(host.Host)] = &cert
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.defaultCert = defaultCert
+	s.hosts = hosts
+
+	return nil
+}
+
+// getCertificate returns the certificate for the server name sent by the client. Host certificates are matched
+// exactly and then by wildcard, the default certificate is used for other server names.
+func (s *certificateStore) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+
+	if cert, ok := s.hosts[name]; ok {
+		return cert, nil
+	}
+
+	if i := strings.Index(name, "."); i > 0 {
+		if cert, ok := s.hosts["*"+name[i:]]; ok {
+			return cert, nil
+		}
+	}
+
+	if s.defaultCert != nil {
+		return s.defaultCert, nil
+	}
+
+	return nil, fmt.Errorf("no certificate for server name %q", hello.ServerName)
+}
+
+// covers returns true if a certificate is served for host
+func (s *certificateStore) covers(host string) bool {
+	_, err := s.getCertificate(&tls.ClientHelloInfo{ServerName: host})
+
+	return err == nil
+}
+
+// files returns the certificate and key files in the store
+func (s *certificateStore) files() []string {
+	var files []string
+	if s.cfg.Cert != "" {
+		files = append(files, s.cfg.Cert, s.cfg.Key)
+	}
+	for _, host := range s.cfg.Hosts {
+		files = append(files, host.Cert, host.Key)
+	}
+
+	return files
+}
+
+// watch reloads the certificates whenever their files change, until ctx is cancelled. Failed reloads are logged and
+// the current certificates are kept.
+func (s *certificateStore) watch(ctx context.Context) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create certificate watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	// directories are watched rather than files, since certificate managers and Kubernetes secrets replace the files
+	// rather than writing to them
+	dirs := make(map[string]bool)
+	for _, file := range s.files() {
+		dir := filepath.Dir(file)
+		if dirs[dir] {
+			continue
+		}
+		dirs[dir] = true
+
+		err = watcher.Add(dir)
+		if err != nil {
+			return fmt.Errorf("failed to watch certificate directory %s: %w", dir, err)
+		}
+	}
+
+	var reloadCh <-chan time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case _, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			// certificates and keys are often replaced one after the other, so changes are left to settle
+			reloadCh = time.After(configReloadDelay)
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			log.Printf("error watching certificates: %v", err)
+		case <-reloadCh:
+			reloadCh = nil
+
+			err := s.reload()
+			if err != nil {
+				log.Printf("failed to reload certificates: %v", err)
+				continue
+			}
+
+			log.Println("reloaded certificates")
+		}
+	}
+}
+
+// checkHostCertificates logs a warning for each tool using the HTTPHost feature which has no certificate
+func (b *Belt) checkHostCertificates(store *certificateStore) {
+	for _, t := range b.tools {
+		httpTool, ok := t.(apis.HTTPTool)
+		if !t.FeatureSet().HTTP || !t.FeatureSet().HTTPHost || !ok {
+			continue
+		}
+
+		if !store.covers(httpTool.HTTPHost()) {
+			log.Printf(
+				"no certificate for host %q of tool %q, TLS connections to the host will fail",
+				httpTool.HTTPHost(), t.Name(),
+			)
+		}
+	}
+}
+
+// httpsRedirectHandler redirects requests to the same host and path on the HTTPS port
+func httpsRedirectHandler(httpsPort string) http.Handler {
+	return http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		host := request.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+
+		if httpsPort != "443" {
+			host = net.JoinHostPort(host, httpsPort)
+		}
+
+		target := "https://" + host + request.URL.RequestURI()
+
+		http.Redirect(writer, request, target, http.StatusPermanentRedirect)
+	})
+}
@@ -0,0 +1,190 @@
+package tool_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/charlieegan3/toolbelt/pkg/tool"
+)
+
+// writeCertificate writes a self-signed certificate with the common name to dir, and returns the cert and key files
+func writeCertificate(t *testing.T, dir, commonName string) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	certFile := filepath.Join(dir, commonName+".crt")
+	keyFile := filepath.Join(dir, commonName+".key")
+
+	require.NoError(t, os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600))
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600))
+
+	return certFile, keyFile
+}
+
+// servedCertificate returns the common name of the certificate served for the server name
+func servedCertificate(addr net.Addr, serverName string) (string, error) {
+	conn, err := tls.Dial("tcp", addr.String(), &tls.Config{
+		ServerName: serverName,
+		// the certificates are self-signed
+		InsecureSkipVerify: true,
+	})
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestStartServerTLS(t *testing.T) {
+	dir := t.TempDir()
+
+	defaultCert, defaultKey := writeCertificate(t, dir, "default")
+	exampleCert, exampleKey := writeCertificate(t, dir, "example")
+	appsCert, appsKey := writeCertificate(t, dir, "apps")
+
+	tb := tool.NewBelt()
+	tb.SetConfig(map[string]any{
+		"server": map[string]any{
+			"tls": map[string]any{
+				"cert": defaultCert,
+				"key":  defaultKey,
+				"hosts": []any{
+					map[string]any{"host": "example.com", "cert": exampleCert, "key": exampleKey},
+					map[string]any{"host": "*.apps.example.com", "cert": appsCert, "key": appsKey},
+				},
+				"redirectAddr": "127.0.0.1:0",
+			},
+		},
+	})
+
+	server, err := tb.StartServer("127.0.0.1:0")
+	require.NoError(t, err)
+	defer server.Shutdown(context.Background())
+
+	t.Run("serves HTTPS", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		}}
+
+		resp, err := client.Get(fmt.Sprintf("https://%s/readyz", server.Addr()))
+		require.NoError(t, err)
+		resp.Body.Close()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("selects certificates by server name", func(t *testing.T) {
+		for serverName, expected := range map[string]string{
+			"example.com":            "example",
+			"EXAMPLE.com":            "example",
+			"web.apps.example.com":   "apps",
+			"other.example.com":      "default",
+			"a.web.apps.example.com": "default",
+			"":                       "default",
+		} {
+			commonName, err := servedCertificate(server.Addr(), serverName)
+			require.NoError(t, err, serverName)
+			require.Equal(t, expected, commonName, serverName)
+		}
+	})
+
+	t.Run("redirects HTTP to HTTPS", func(t *testing.T) {
+		client := &http.Client{
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		}
+
+		resp, err := client.Get(fmt.Sprintf("http://%s/example/path?a=b", server.RedirectAddr()))
+		require.NoError(t, err)
+		resp.Body.Close()
+
+		_, port, err := net.SplitHostPort(server.Addr().String())
+		require.NoError(t, err)
+
+		require.Equal(t, http.StatusPermanentRedirect, resp.StatusCode)
+		require.Equal(t, "https://127.0.0.1:"+port+"/example/path?a=b", resp.Header.Get("Location"))
+	})
+
+	t.Run("reloads certificates when they change", func(t *testing.T) {
+		renewedCert, renewedKey := writeCertificate(t, t.TempDir(), "renewed")
+
+		for src, dst := range map[string]string{renewedCert: exampleCert, renewedKey: exampleKey} {
+			data, err := os.ReadFile(src)
+			require.NoError(t, err)
+
+			// files are replaced rather than written to, as certificate managers do
+			tmp := dst + ".tmp"
+			require.NoError(t, os.WriteFile(tmp, data, 0o600))
+			require.NoError(t, os.Rename(tmp, dst))
+		}
+
+		require.Eventually(t, func() bool {
+			commonName, err := servedCertificate(server.Addr(), "example.com")
+			return err == nil && commonName == "renewed"
+		}, 5*time.Second, 50*time.Millisecond)
+	})
+}
+
+func TestStartServerTLSInvalidConfig(t *testing.T) {
+	dir := t.TempDir()
+	cert, _ := writeCertificate(t, dir, "default")
+
+	testCases := map[string]struct {
+		tls           map[string]any
+		expectedError string
+	}{
+		"cert without key": {
+			tls:           map[string]any{"cert": cert},
+			expectedError: "cert and key must be set together",
+		},
+		"missing file": {
+			tls:           map[string]any{"cert": cert, "key": filepath.Join(dir, "missing.key")},
+			expectedError: "failed to load default certificate",
+		},
+		"host without a certificate": {
+			tls:           map[string]any{"hosts": []any{map[string]any{"host": "example.com"}}},
+			expectedError: "invalid server TLS config",
+		},
+	}
+
+	for name, testCase := range testCases {
+		t.Run(name, func(t *testing.T) {
+			tb := tool.NewBelt()
+			tb.SetConfig(map[string]any{"server": map[string]any{"tls": testCase.tls}})
+
+			_, err := tb.StartServer("127.0.0.1:0")
+			require.ErrorContains(t, err, testCase.expectedError)
+			require.Nil(t, tb.ServerAddr())
+		})
+	}
+}